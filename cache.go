@@ -0,0 +1,358 @@
+package rocketqa
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errComputeAborted is returned to the callers waiting for an inference that
+// panicked in another goroutine.
+var errComputeAborted = errors.New("the inference of a concurrent identical request was aborted")
+
+type EmbeddingCacheConfig struct {
+	// The maximum memory used by the cached entries, in bytes. Defaults to
+	// 64 MiB, which holds about 20,000 vectors of dimension 768.
+	MaxBytes int64
+	// How long a cached vector stays valid. Zero means forever.
+	TTL time.Duration
+}
+
+// EmbeddingCacheStats holds the counters of an EmbeddingCache.
+type EmbeddingCacheStats struct {
+	// The number of lookups served from the cache.
+	Hits int64
+	// The number of lookups that required an inference.
+	Misses int64
+	// The number of lookups that waited for the inference of a concurrent
+	// identical lookup, instead of running their own.
+	Shared int64
+	// The number of entries removed to keep the memory bounded.
+	Evictions int64
+	// The number of entries removed because their TTL had passed.
+	Expirations int64
+
+	Entries int
+	Bytes   int64
+}
+
+// HitRate returns the fraction of lookups that did not require an inference.
+func (s EmbeddingCacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses + s.Shared
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.Shared) / float64(total)
+}
+
+// EmbeddingCache is an in-memory LRU cache of vectors, which is safe for
+// concurrent use. Concurrent lookups of the same missing key only trigger
+// one computation.
+type EmbeddingCache struct {
+	maxBytes int64
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	ll       *list.List // Most recently used at the front.
+	items    map[string]*list.Element
+	inflight map[string]*flight
+	stats    EmbeddingCacheStats
+}
+
+type cacheEntry struct {
+	key     string
+	vector  Vector
+	expires time.Time
+}
+
+// size returns the approximate memory used by the entry.
+func (e *cacheEntry) size() int64 {
+	const overhead = 64 // The list element, the map slot and the headers.
+	return int64(len(e.key)+4*len(e.vector)) + overhead
+}
+
+type flight struct {
+	done   chan struct{}
+	vector Vector
+	err    error
+}
+
+func NewEmbeddingCache(cfg *EmbeddingCacheConfig) *EmbeddingCache {
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	return &EmbeddingCache{
+		maxBytes: maxBytes,
+		ttl:      cfg.TTL,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		inflight: make(map[string]*flight),
+	}
+}
+
+// GetOrCompute returns the vectors of the keys. The missing ones are computed
+// in one batch by calling compute with their indices in keys, which must
+// return the vectors in the same order.
+//
+// Errors from compute are returned as is and never cached. The returned
+// vectors are copies, which can be modified freely.
+func (c *EmbeddingCache) GetOrCompute(keys []string, compute func(indices []int) ([]Vector, error)) ([]Vector, error) {
+	vectors := make([]Vector, len(keys))
+	waits := make(map[int]*flight)
+	var owned []int
+
+	c.mu.Lock()
+	now := time.Now()
+	for i, k := range keys {
+		if v, ok := c.lookup(k, now); ok {
+			vectors[i] = clone(v)
+			c.stats.Hits++
+			continue
+		}
+		// This also deduplicates identical keys within the same batch.
+		if f, ok := c.inflight[k]; ok {
+			waits[i] = f
+			c.stats.Shared++
+			continue
+		}
+		c.inflight[k] = &flight{done: make(chan struct{})}
+		owned = append(owned, i)
+		c.stats.Misses++
+	}
+	c.mu.Unlock()
+
+	if len(owned) > 0 {
+		computed, err := c.compute(keys, owned, compute)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range owned {
+			vectors[i] = computed[j]
+		}
+	}
+
+	for i, f := range waits {
+		<-f.done
+		if f.err != nil {
+			return nil, f.err
+		}
+		vectors[i] = clone(f.vector)
+	}
+	return vectors, nil
+}
+
+// compute computes the vectors of the owned keys, and completes their flights.
+func (c *EmbeddingCache) compute(keys []string, owned []int, compute func(indices []int) ([]Vector, error)) (computed []Vector, err error) {
+	completed := false
+	defer func() {
+		// Release the waiters if compute panicked.
+		if !completed {
+			c.complete(keys, owned, nil, errComputeAborted)
+		}
+	}()
+
+	computed, err = compute(owned)
+	if err == nil && len(computed) != len(owned) {
+		err = fmt.Errorf("got %d vectors, want %d", len(computed), len(owned))
+	}
+	if err != nil {
+		computed = nil
+	}
+
+	c.complete(keys, owned, computed, err)
+	completed = true
+	return computed, err
+}
+
+func (c *EmbeddingCache) complete(keys []string, owned []int, computed []Vector, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for j, i := range owned {
+		k := keys[i]
+		f := c.inflight[k]
+		delete(c.inflight, k)
+
+		if err != nil {
+			f.err = err
+		} else {
+			// Keep a private copy, since the caller owns computed[j].
+			f.vector = clone(computed[j])
+			c.add(k, f.vector, now)
+		}
+		close(f.done)
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (c *EmbeddingCache) Stats() EmbeddingCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats
+	s.Entries = c.ll.Len()
+	return s
+}
+
+// Purge removes all the entries, but keeps the counters.
+func (c *EmbeddingCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.stats.Bytes = 0
+}
+
+func (c *EmbeddingCache) lookup(key string, now time.Time) (Vector, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*cacheEntry)
+	if c.ttl > 0 && now.After(e.expires) {
+		c.remove(el)
+		c.stats.Expirations++
+		return nil, false
+	}
+
+	c.ll.MoveToFront(el)
+	return e.vector, true
+}
+
+func (c *EmbeddingCache) add(key string, v Vector, now time.Time) {
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+
+	e := &cacheEntry{key: key, vector: v}
+	if c.ttl > 0 {
+		e.expires = now.Add(c.ttl)
+	}
+	c.items[key] = c.ll.PushFront(e)
+	c.stats.Bytes += e.size()
+
+	for c.stats.Bytes > c.maxBytes && c.ll.Len() > 0 {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *EmbeddingCache) remove(el *list.Element) {
+	e := c.ll.Remove(el).(*cacheEntry)
+	delete(c.items, e.key)
+	c.stats.Bytes -= e.size()
+}
+
+func clone(v Vector) Vector {
+	return append(Vector(nil), v...)
+}
+
+// CachedDualEncoder is a DualEncoder whose outputs are cached. Keys are the
+// hashes of the whitespace-normalized texts and the fingerprint of the
+// encoder (see DualEncoder.Fingerprint), so one cache can be shared by
+// multiple encoders without mixing their vectors. Note that the encoders
+// must not share a cache if they have different transforms without
+// fingerprints of the same type (see Transformer).
+type CachedDualEncoder struct {
+	encoder *DualEncoder
+	cache   *EmbeddingCache
+}
+
+func NewCachedDualEncoder(de *DualEncoder, cache *EmbeddingCache) *CachedDualEncoder {
+	return &CachedDualEncoder{encoder: de, cache: cache}
+}
+
+// Fingerprint returns the fingerprint of the underlying encoder.
+func (c *CachedDualEncoder) Fingerprint() string {
+	return c.encoder.fingerprint
+}
+
+// Cache returns the underlying cache, e.g. for reading its stats.
+func (c *CachedDualEncoder) Cache() *EmbeddingCache {
+	return c.cache
+}
+
+// EncodeQuery is like EncodeQueries, but panics with the error, if any.
+//
+// Deprecated: Use EncodeQueries, which returns the errors instead.
+func (c *CachedDualEncoder) EncodeQuery(queries []string) []Vector {
+	return mustVectors(c.EncodeQueries(context.Background(), queries))
+}
+
+// EncodeQueries is like DualEncoder.EncodeQueries, but only encodes the
+// queries missing from the cache. It fails with the error of a concurrent
+// identical inference, if it shares one that fails.
+func (c *CachedDualEncoder) EncodeQueries(ctx context.Context, queries []string) ([]Vector, error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(queries))
+	for i, q := range queries {
+		keys[i] = cacheKey(c.encoder.fingerprint, "query", q)
+	}
+
+	return c.cache.GetOrCompute(keys, func(indices []int) ([]Vector, error) {
+		missing := make([]string, len(indices))
+		for j, i := range indices {
+			missing[j] = queries[i]
+		}
+		return c.encoder.EncodeQueries(ctx, missing)
+	})
+}
+
+func (c *CachedDualEncoder) EncodePara(paras, titles []string) ([]Vector, error) {
+	n := len(paras)
+	if n == 0 {
+		return nil, nil
+	}
+	if err := checkTitles(paras, titles); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, n)
+	for i := range paras {
+		keys[i] = cacheKey(c.encoder.fingerprint, "para", titleAt(titles, i), paras[i])
+	}
+
+	return c.cache.GetOrCompute(keys, func(indices []int) ([]Vector, error) {
+		missingParas := make([]string, len(indices))
+		missingTitles := make([]string, len(indices))
+		for j, i := range indices {
+			missingParas[j], missingTitles[j] = paras[i], titleAt(titles, i)
+		}
+		return c.encoder.EncodePara(missingParas, missingTitles)
+	})
+}
+
+// cacheKey returns the hash of the fingerprint, the kind of the texts and the
+// whitespace-normalized texts. Runs of whitespace are insignificant to the
+// tokenizer, so normalizing them does not change the vectors.
+func cacheKey(fingerprint, kind string, texts ...string) string {
+	h := sha256.New()
+	writeField(h, fingerprint)
+	writeField(h, kind)
+	for _, t := range texts {
+		writeField(h, strings.Join(strings.Fields(t), " "))
+	}
+	return string(h.Sum(nil))
+}
+
+// writeField writes s to h, prefixed by its length to avoid ambiguity.
+func writeField(h hash.Hash, s string) {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(s)))
+	h.Write(n[:])
+	h.Write([]byte(s))
+}
@@ -0,0 +1,262 @@
+package rocketqa_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEmbeddingCache_GetOrCompute(t *testing.T) {
+	cache := rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{})
+
+	var computed [][]int
+	compute := func(indices []int) ([]rocketqa.Vector, error) {
+		computed = append(computed, indices)
+		var vectors []rocketqa.Vector
+		for _, i := range indices {
+			vectors = append(vectors, rocketqa.Vector{float32(i)})
+		}
+		return vectors, nil
+	}
+
+	// The duplicate "a" is only computed once.
+	got, err := cache.GetOrCompute([]string{"a", "b", "a"}, compute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rocketqa.Vector{{0}, {1}, {0}}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Vectors (Want - Got): %s", diff)
+	}
+
+	// Modifying the returned vectors does not affect the cache.
+	got[0][0] = 100
+
+	got, err = cache.GetOrCompute([]string{"c", "a"}, compute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []rocketqa.Vector{{0}, {0}}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Vectors (Want - Got): %s", diff)
+	}
+
+	wantComputed := [][]int{{0, 1}, {0}}
+	if !cmp.Equal(computed, wantComputed) {
+		diff := cmp.Diff(computed, wantComputed)
+		t.Errorf("Computed (Want - Got): %s", diff)
+	}
+
+	gotStats := cache.Stats()
+	gotStats.Bytes = 0
+	wantStats := rocketqa.EmbeddingCacheStats{Hits: 1, Misses: 3, Shared: 1, Entries: 3}
+	if !cmp.Equal(gotStats, wantStats) {
+		diff := cmp.Diff(gotStats, wantStats)
+		t.Errorf("Stats (Want - Got): %s", diff)
+	}
+}
+
+func TestEmbeddingCache_Bounds(t *testing.T) {
+	compute := func(indices []int) ([]rocketqa.Vector, error) {
+		vectors := make([]rocketqa.Vector, len(indices))
+		for i := range vectors {
+			vectors[i] = make(rocketqa.Vector, 16)
+		}
+		return vectors, nil
+	}
+
+	t.Run("max bytes", func(t *testing.T) {
+		cache := rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{})
+		if _, err := cache.GetOrCompute([]string{"a"}, compute); err != nil {
+			t.Fatal(err)
+		}
+		entrySize := cache.Stats().Bytes
+
+		// Room for two entries only.
+		cache = rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{MaxBytes: 2 * entrySize})
+		for _, keys := range [][]string{{"a", "b"}, {"a"}, {"c"}} {
+			if _, err := cache.GetOrCompute(keys, compute); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// "b" is the least recently used, and has been evicted.
+		if _, err := cache.GetOrCompute([]string{"a", "b", "c"}, compute); err != nil {
+			t.Fatal(err)
+		}
+
+		got := cache.Stats()
+		want := rocketqa.EmbeddingCacheStats{Hits: 3, Misses: 4, Evictions: 2, Entries: 2, Bytes: 2 * entrySize}
+		if !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		cache := rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{TTL: 20 * time.Millisecond})
+		for i := 0; i < 2; i++ {
+			if _, err := cache.GetOrCompute([]string{"a"}, compute); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(40 * time.Millisecond)
+		if _, err := cache.GetOrCompute([]string{"a"}, compute); err != nil {
+			t.Fatal(err)
+		}
+
+		got := cache.Stats()
+		got.Bytes = 0
+		want := rocketqa.EmbeddingCacheStats{Hits: 1, Misses: 2, Expirations: 1, Entries: 1}
+		if !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	})
+}
+
+func TestEmbeddingCache_Singleflight(t *testing.T) {
+	cache := rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	calls := 0
+	compute := func(indices []int) ([]rocketqa.Vector, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		close(started)
+		<-release
+		return []rocketqa.Vector{{1, 2}}, nil
+	}
+
+	const n = 8
+	results := make([][]rocketqa.Vector, n)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _ = cache.GetOrCompute([]string{"q"}, compute)
+	}()
+	<-started
+
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.GetOrCompute([]string{"q"}, compute)
+		}(i)
+	}
+	// Wait for the other lookups to join the inflight computation.
+	for cache.Stats().Shared < n-1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+	for i, got := range results {
+		want := []rocketqa.Vector{{1, 2}}
+		if !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Result %d (Want - Got): %s", i, diff)
+		}
+	}
+}
+
+func TestEmbeddingCache_Error(t *testing.T) {
+	cache := rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{})
+
+	wantErr := errors.New("inference failed")
+	_, err := cache.GetOrCompute([]string{"a"}, func([]int) ([]rocketqa.Vector, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("got error %v, want %v", err, wantErr)
+	}
+
+	// Errors are not cached.
+	got, err := cache.GetOrCompute([]string{"a"}, func([]int) ([]rocketqa.Vector, error) {
+		return []rocketqa.Vector{{1}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rocketqa.Vector{{1}}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestCachedDualEncoder(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cde := rocketqa.NewCachedDualEncoder(de, rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{}))
+
+	queries := []string{"你好，世界！", "Hello, World!"}
+	wantQuery, err := de.EncodeQueries(context.Background(), queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second batch hits the cache, even with extra whitespace.
+	for _, qs := range [][]string{queries, {" 你好，世界！", "Hello,  World!\n"}} {
+		got, err := cde.EncodeQueries(context.Background(), qs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cmp.Equal(got, wantQuery) {
+			diff := cmp.Diff(got, wantQuery)
+			t.Errorf("Query (Want - Got): %s", diff)
+		}
+	}
+
+	paras, titles := []string{"这是一段较长的文本。", "This is a long paragraph."}, []string{"", ""}
+	wantPara, err := de.EncodePara(paras, titles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotPara, err := cde.EncodePara(paras, titles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(gotPara, wantPara) {
+		diff := cmp.Diff(gotPara, wantPara)
+		t.Errorf("Para (Want - Got): %s", diff)
+	}
+
+	stats := cde.Cache().Stats()
+	if stats.Hits != 2 || stats.Misses != 4 {
+		t.Errorf("got %d hits and %d misses, want 2 and 4", stats.Hits, stats.Misses)
+	}
+}
+
+func TestCachedDualEncoder_Error(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cde := rocketqa.NewCachedDualEncoder(de, rocketqa.NewEmbeddingCache(&rocketqa.EmbeddingCacheConfig{}))
+	if err := de.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cde.EncodeQueries(context.Background(), []string{"你好"}); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("EncodeQueries: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
+	// The errors are not cached.
+	if stats := cde.Cache().Stats(); stats.Entries != 0 {
+		t.Errorf("got %d entries, want 0", stats.Entries)
+	}
+}
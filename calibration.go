@@ -0,0 +1,212 @@
+package rocketqa
+
+import (
+	"fmt"
+	"math"
+)
+
+// Calibrator maps relevance logits to calibrated probabilities, so that the
+// scores (and any thresholds on them) mean the same thing across models.
+type Calibrator interface {
+	Calibrate(logit float32) float32
+}
+
+// TemperatureScaling calibrates logits by sigmoid(logit / T), where T must
+// be positive and finite.
+type TemperatureScaling struct {
+	T float64
+}
+
+// NewTemperatureScaling returns a TemperatureScaling with temperature t, or
+// an error if t is not positive and finite.
+func NewTemperatureScaling(t float64) (TemperatureScaling, error) {
+	c := TemperatureScaling{T: t}
+	if err := c.validate(); err != nil {
+		return TemperatureScaling{}, err
+	}
+	return c, nil
+}
+
+func (c TemperatureScaling) validate() error {
+	if !(c.T > 0) || math.IsInf(c.T, 1) {
+		return fmt.Errorf("temperature must be positive and finite, got %v", c.T)
+	}
+	return nil
+}
+
+func (c TemperatureScaling) Calibrate(logit float32) float32 {
+	return sigmoid(float32(float64(logit) / c.T))
+}
+
+// PlattScaling calibrates logits by sigmoid(A * logit + B).
+type PlattScaling struct {
+	A, B float64
+}
+
+func (c PlattScaling) Calibrate(logit float32) float32 {
+	return sigmoid(float32(c.A*float64(logit) + c.B))
+}
+
+// FitTemperature learns a TemperatureScaling from the logits of labeled
+// (query, para) pairs, where labels[i] tells whether the i-th pair is
+// relevant. See CrossEncoder.Logits for getting the logits.
+func FitTemperature(logits []float32, labels []bool) (TemperatureScaling, error) {
+	if err := checkCalibrationData(logits, labels); err != nil {
+		return TemperatureScaling{}, err
+	}
+
+	// Minimize the negative log-likelihood over a = 1/T by Newton's method.
+	a := 1.0
+	for iter := 0; iter < 100; iter++ {
+		var g, h float64
+		for i, l := range logits {
+			f := float64(l)
+			p := sigmoid64(a * f)
+			g += (p - target(labels[i])) * f
+			h += p * (1 - p) * f * f
+		}
+		if h < 1e-12 {
+			break
+		}
+
+		step := g / h
+		// Backtrack if the step does not decrease the loss.
+		loss := nll(logits, labels, a, 0, target)
+		for step != 0 && nll(logits, labels, a-step, 0, target) > loss {
+			step /= 2
+			if math.Abs(step) < 1e-10 {
+				step = 0
+			}
+		}
+		a -= step
+		if math.Abs(step) < 1e-8 {
+			break
+		}
+	}
+
+	if a <= 0 {
+		return TemperatureScaling{}, fmt.Errorf("logits are not positively correlated with labels")
+	}
+	return NewTemperatureScaling(1 / a)
+}
+
+// FitPlatt learns a PlattScaling from the logits of labeled (query, para)
+// pairs, where labels[i] tells whether the i-th pair is relevant. See
+// CrossEncoder.Logits for getting the logits.
+//
+// It follows Platt (1999) in using smoothed targets, and Lin et al. (2007)
+// in using Newton's method with backtracking line search.
+func FitPlatt(logits []float32, labels []bool) (PlattScaling, error) {
+	if err := checkCalibrationData(logits, labels); err != nil {
+		return PlattScaling{}, err
+	}
+
+	var nPos, nNeg float64
+	for _, l := range labels {
+		if l {
+			nPos++
+		} else {
+			nNeg++
+		}
+	}
+	hiTarget, loTarget := (nPos+1)/(nPos+2), 1/(nNeg+2)
+	smoothed := func(label bool) float64 {
+		if label {
+			return hiTarget
+		}
+		return loTarget
+	}
+
+	a, b := 1.0, math.Log((nPos+1)/(nNeg+1))
+	for iter := 0; iter < 100; iter++ {
+		var gA, gB, hAA, hAB, hBB float64
+		for i, l := range logits {
+			f := float64(l)
+			p := sigmoid64(a*f + b)
+			d := p - smoothed(labels[i])
+			w := p * (1 - p)
+			gA += d * f
+			gB += d
+			hAA += w * f * f
+			hAB += w * f
+			hBB += w
+		}
+		// Regularize the Hessian to keep it positive definite.
+		hAA += 1e-12
+		hBB += 1e-12
+
+		det := hAA*hBB - hAB*hAB
+		if det == 0 {
+			break
+		}
+		stepA := (hBB*gA - hAB*gB) / det
+		stepB := (hAA*gB - hAB*gA) / det
+
+		loss := nll(logits, labels, a, b, smoothed)
+		t := 1.0
+		for t > 1e-10 && nll(logits, labels, a-t*stepA, b-t*stepB, smoothed) > loss {
+			t /= 2
+		}
+		if t <= 1e-10 {
+			break
+		}
+		a -= t * stepA
+		b -= t * stepB
+		if math.Abs(t*stepA) < 1e-8 && math.Abs(t*stepB) < 1e-8 {
+			break
+		}
+	}
+
+	return PlattScaling{A: a, B: b}, nil
+}
+
+func checkCalibrationData(logits []float32, labels []bool) error {
+	if len(logits) != len(labels) {
+		return &LengthError{Arg: "labels", Len: len(labels), WantArg: "logits", WantLen: len(logits)}
+	}
+
+	var hasPos, hasNeg bool
+	for _, l := range labels {
+		if l {
+			hasPos = true
+		} else {
+			hasNeg = true
+		}
+	}
+	if !hasPos || !hasNeg {
+		return fmt.Errorf("labels must contain both relevant and irrelevant pairs")
+	}
+	return nil
+}
+
+// nll returns the negative log-likelihood of sigmoid(a * logit + b) against
+// the targets of the labels.
+func nll(logits []float32, labels []bool, a, b float64, target func(bool) float64) float64 {
+	var loss float64
+	for i, l := range logits {
+		z := a*float64(l) + b
+		t := target(labels[i])
+		// log(1 + exp(z)) - t*z, computed in a numerically stable way.
+		if z > 0 {
+			loss += z + math.Log1p(math.Exp(-z)) - t*z
+		} else {
+			loss += math.Log1p(math.Exp(z)) - t*z
+		}
+	}
+	return loss
+}
+
+func target(label bool) float64 {
+	if label {
+		return 1
+	}
+	return 0
+}
+
+func sigmoid(x float32) float32 {
+	return float32(sigmoid64(float64(x)))
+}
+
+func sigmoid64(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
@@ -0,0 +1,107 @@
+package rocketqa_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+)
+
+func TestFitPlatt(t *testing.T) {
+	logits, labels := syntheticCalibrationData(1, 5000, func(f float64) float64 {
+		return 2*f - 1
+	})
+
+	c, err := rocketqa.FitPlatt(logits, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(c.A-2) > 0.2 || math.Abs(c.B+1) > 0.2 {
+		t.Errorf("Got (A=%v, B=%v), want about (A=2, B=-1)", c.A, c.B)
+	}
+}
+
+func TestFitTemperature(t *testing.T) {
+	logits, labels := syntheticCalibrationData(1, 5000, func(f float64) float64 {
+		return f / 2
+	})
+
+	c, err := rocketqa.FitTemperature(logits, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(c.T-2) > 0.2 {
+		t.Errorf("Got (T=%v), want about (T=2)", c.T)
+	}
+
+	if got := c.Calibrate(0); got != 0.5 {
+		t.Errorf("Calibrate(0): Got (%v) != Want (0.5)", got)
+	}
+}
+
+func TestFit_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		inLogits []float32
+		inLabels []bool
+	}{
+		{"mismatched lengths", []float32{1, 2}, []bool{true}},
+		{"only positives", []float32{1, 2}, []bool{true, true}},
+		{"only negatives", []float32{1, 2}, []bool{false, false}},
+	}
+	for _, tt := range tests {
+		if _, err := rocketqa.FitPlatt(tt.inLogits, tt.inLabels); err == nil {
+			t.Errorf("FitPlatt: %s: Want error", tt.name)
+		}
+		if _, err := rocketqa.FitTemperature(tt.inLogits, tt.inLabels); err == nil {
+			t.Errorf("FitTemperature: %s: Want error", tt.name)
+		}
+	}
+}
+
+func TestNewTemperatureScaling(t *testing.T) {
+	tests := []struct {
+		inT     float64
+		wantErr bool
+	}{
+		{inT: 2, wantErr: false},
+		{inT: 0, wantErr: true},
+		{inT: -1, wantErr: true},
+		{inT: math.NaN(), wantErr: true},
+		{inT: math.Inf(1), wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := rocketqa.NewTemperatureScaling(tt.inT)
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("NewTemperatureScaling(%v): Got error (%v), want error: %v", tt.inT, err, tt.wantErr)
+		}
+	}
+
+	// A fit leaving no valid temperature fails.
+	inf := float32(math.Inf(1))
+	if c, err := rocketqa.FitTemperature([]float32{inf, -inf}, []bool{true, false}); err == nil {
+		t.Errorf("FitTemperature: Got (T=%v), want error", c.T)
+	}
+
+	cfg := newCrossEncoderConfig(1)
+	cfg.Calibrator = rocketqa.TemperatureScaling{}
+	if _, err := rocketqa.NewCrossEncoder(cfg); err == nil {
+		t.Errorf("NewCrossEncoder: Want error for zero temperature")
+	}
+}
+
+// syntheticCalibrationData generates logits uniformly from [-3, 3], whose
+// labels are drawn with the probability sigmoid(trueLogit(logit)).
+func syntheticCalibrationData(seed int64, n int, trueLogit func(float64) float64) ([]float32, []bool) {
+	r := rand.New(rand.NewSource(seed))
+
+	logits := make([]float32, n)
+	labels := make([]bool, n)
+	for i := range logits {
+		f := r.Float64()*6 - 3
+		logits[i] = float32(f)
+		labels[i] = r.Float64() < 1/(1+math.Exp(-trueLogit(f)))
+	}
+	return logits, labels
+}
@@ -0,0 +1,121 @@
+// Command distill labels the (query, paragraph) pairs of a retrieval run with
+// the scores of a cross encoder, for distilling the dual encoder. See package
+// distill for the output format.
+//
+// Usage:
+//
+//	distill -ce=models/ce -corpus=corpus.tsv -queries=queries.tsv -run=run.txt -out=labels.tsv [-shard=0 -shards=4]
+//
+// The run is in the TREC run format, such as the one written by "eval -run".
+// Run the same command again to resume an interrupted job. To split a job
+// across machines, run every shard with its own output file, and then
+// concatenate the outputs.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/cmd/internal/cmdutil"
+	"github.com/go-aie/rocketqa/distill"
+	"github.com/go-aie/rocketqa/eval"
+)
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	corpusFile := flag.String("corpus", "", `The corpus file, with lines of "id\ttitle\tpara" or "id\tpara"`)
+	queriesFile := flag.String("queries", "", `The queries file, with lines of "id\tquery"`)
+	runFile := flag.String("run", "", "The run file to label, in TREC run format")
+	ceDir := flag.String("ce", "", "The directory of the cross encoder model")
+	vocabFile := flag.String("vocab", "", "The vocab file, if not in the model directory")
+	doLowerCase := flag.Bool("lower", true, "Whether to lowercase the texts")
+	forCN := flag.Bool("cn", true, "Whether the model is for Chinese")
+	ceLen := flag.Int("ce-len", 384, "The maximum sequence length of the cross encoder")
+	scoreName := flag.String("score", "probability", `The score of the labels, either "probability" or "logit"`)
+	var cfg distill.Config
+	flag.StringVar(&cfg.Path, "out", "", "The output file, which is appended to when resuming")
+	flag.IntVar(&cfg.Shard, "shard", 0, "The shard to label, in [0, shards)")
+	flag.IntVar(&cfg.Shards, "shards", 1, "The number of shards")
+	flag.IntVar(&cfg.Depth, "depth", 0, "The maximum number of candidates per query (defaults to all)")
+	flag.IntVar(&cfg.BatchSize, "batch", 32, "The number of pairs per inference")
+	flag.Parse()
+
+	if *corpusFile == "" || *queriesFile == "" || *runFile == "" || *ceDir == "" || cfg.Path == "" {
+		return errors.New(`arguments "corpus", "queries", "run", "ce" and "out" are required`)
+	}
+
+	var score rocketqa.ScoreType
+	switch *scoreName {
+	case "probability":
+		score = rocketqa.ScoreProbability
+	case "logit":
+		score = rocketqa.ScoreLogit
+	default:
+		return fmt.Errorf("unknown score %q", *scoreName)
+	}
+
+	corpus, err := cmdutil.ReadFile(*corpusFile, eval.ReadCorpus)
+	if err != nil {
+		return err
+	}
+	queries, err := cmdutil.ReadFile(*queriesFile, eval.ReadQueries)
+	if err != nil {
+		return err
+	}
+	retrieved, err := cmdutil.ReadFile(*runFile, eval.ReadRun)
+	if err != nil {
+		return err
+	}
+
+	files, err := rocketqa.FindModelFiles(*ceDir)
+	if err != nil {
+		return err
+	}
+	if *vocabFile != "" {
+		files.VocabFile = *vocabFile
+	}
+	if files.VocabFile == "" {
+		return fmt.Errorf(`found no vocab file in %s, use argument "vocab"`, *ceDir)
+	}
+	ce, err := rocketqa.NewCrossEncoder(&rocketqa.CrossEncoderConfig{
+		ModelPath:    files.ModelPath,
+		ParamsPath:   files.ParamsPath,
+		VocabFile:    files.VocabFile,
+		DoLowerCase:  *doLowerCase,
+		MaxSeqLength: *ceLen,
+		ForCN:        *forCN,
+		Score:        score,
+	})
+	if err != nil {
+		return err
+	}
+	defer ce.Close()
+	cfg.Ranker = ce
+
+	// Stop after the current query on interruption, so that the job can be
+	// resumed cleanly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	slog.Info("labeling", "queries", len(queries), "shard", cfg.Shard, "shards", cfg.Shards)
+	stats, err := distill.Label(ctx, &cfg, queries, corpus, retrieved)
+	slog.Info("labeled",
+		"queries", stats.Queries,
+		"pairs", stats.Pairs,
+		"resumed", stats.Resumed,
+		"missing", stats.Missing,
+	)
+	return err
+}
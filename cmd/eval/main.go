@@ -0,0 +1,137 @@
+// Command eval measures the retrieval quality of a dual encoder (optionally
+// followed by a cross encoder) on a labeled corpus. See package eval for the
+// input formats and the metrics.
+//
+// Usage:
+//
+//	eval -de=models/de -corpus=corpus.tsv -queries=queries.tsv -qrels=qrels.txt [-ce=models/ce] [-run=run.txt] [-per-query=metrics.tsv]
+//
+// The model directories are those accepted by rocketqa.FindModelFiles.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/cmd/internal/cmdutil"
+	"github.com/go-aie/rocketqa/eval"
+)
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	corpusFile := flag.String("corpus", "", `The corpus file, with lines of "id\ttitle\tpara" or "id\tpara"`)
+	queriesFile := flag.String("queries", "", `The queries file, with lines of "id\tquery"`)
+	qrelsFile := flag.String("qrels", "", "The relevance judgments file, in TREC or TSV format")
+	deDir := flag.String("de", "", "The directory of the dual encoder model")
+	ceDir := flag.String("ce", "", "The directory of the cross encoder model for reranking (optional)")
+	vocabFile := flag.String("vocab", "", "The vocab file, if not in the model directories")
+	doLowerCase := flag.Bool("lower", true, "Whether to lowercase the texts")
+	forCN := flag.Bool("cn", true, "Whether the models are for Chinese")
+	queryLen := flag.Int("query-len", 32, "The maximum sequence length of the queries")
+	paraLen := flag.Int("para-len", 384, "The maximum sequence length of the paragraphs")
+	ceLen := flag.Int("ce-len", 384, "The maximum sequence length of the cross encoder")
+	depth := flag.Int("depth", 100, "The number of documents retrieved (and reranked) per query")
+	batchSize := flag.Int("batch", 32, "The number of texts per inference")
+	runFile := flag.String("run", "", "The file to write the retrieved documents to, in TREC run format (optional)")
+	perQueryFile := flag.String("per-query", "", "The file to write the per-query metrics to (optional)")
+	tag := flag.String("tag", "rocketqa", "The run tag in the run file")
+	flag.Parse()
+
+	if *corpusFile == "" || *queriesFile == "" || *qrelsFile == "" || *deDir == "" {
+		return errors.New(`arguments "corpus", "queries", "qrels" and "de" are required`)
+	}
+
+	corpus, err := cmdutil.ReadFile(*corpusFile, eval.ReadCorpus)
+	if err != nil {
+		return err
+	}
+	queries, err := cmdutil.ReadFile(*queriesFile, eval.ReadQueries)
+	if err != nil {
+		return err
+	}
+	qrels, err := cmdutil.ReadFile(*qrelsFile, eval.ReadQrels)
+	if err != nil {
+		return err
+	}
+
+	deFiles, err := cmdutil.FindModelFiles(*deDir, *vocabFile)
+	if err != nil {
+		return err
+	}
+	de, err := rocketqa.NewDualEncoder(&rocketqa.DualEncoderConfig{
+		ModelPath:         deFiles.ModelPath,
+		ParamsPath:        deFiles.ParamsPath,
+		VocabFile:         deFiles.VocabFile,
+		DoLowerCase:       *doLowerCase,
+		QueryMaxSeqLength: *queryLen,
+		ParaMaxSeqLength:  *paraLen,
+		ForCN:             *forCN,
+		Normalize:         true,
+	})
+	if err != nil {
+		return err
+	}
+	defer de.Close()
+
+	cfg := &eval.Config{
+		Encoder:   de,
+		Depth:     *depth,
+		BatchSize: *batchSize,
+	}
+	if *ceDir != "" {
+		ceFiles, err := cmdutil.FindModelFiles(*ceDir, *vocabFile)
+		if err != nil {
+			return err
+		}
+		ce, err := rocketqa.NewCrossEncoder(&rocketqa.CrossEncoderConfig{
+			ModelPath:    ceFiles.ModelPath,
+			ParamsPath:   ceFiles.ParamsPath,
+			VocabFile:    ceFiles.VocabFile,
+			DoLowerCase:  *doLowerCase,
+			MaxSeqLength: *ceLen,
+			ForCN:        *forCN,
+		})
+		if err != nil {
+			return err
+		}
+		defer ce.Close()
+		cfg.Ranker = ce
+	}
+
+	slog.Info("evaluating", "docs", len(corpus), "queries", len(queries), "judged", len(qrels))
+	report, err := eval.Evaluate(context.Background(), cfg, corpus, queries, qrels)
+	if err != nil {
+		return err
+	}
+
+	if *runFile != "" {
+		if err := cmdutil.WriteFile(*runFile, func(w io.Writer) error { return report.WriteRun(w, *tag) }); err != nil {
+			return err
+		}
+	}
+	if *perQueryFile != "" {
+		if err := cmdutil.WriteFile(*perQueryFile, report.WritePerQuery); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "queries\t%d\n", len(report.Queries))
+	for _, name := range report.Names {
+		fmt.Fprintf(w, "%s\t%.4f\n", name, report.Mean[name])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+// Command evalcompare tells whether the differences between two evaluation
+// runs are significant, by comparing the per-query metrics written by
+// "eval -per-query" (or by "trec_eval -q").
+//
+// Usage:
+//
+//	evalcompare [-metrics=mrr@10,ndcg@10] baseline.tsv candidate.tsv
+//
+// For every metric, it reports the means, the per-query wins, losses and
+// ties of the candidate, the p-values of the paired bootstrap test and the
+// paired randomization test, and the queries that regressed the most.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-aie/rocketqa/eval"
+)
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var cfg eval.CompareConfig
+	flag.IntVar(&cfg.Samples, "samples", 10000, "The number of samples of the significance tests")
+	flag.Int64Var(&cfg.Seed, "seed", 1, "The seed of the random number generator")
+	flag.IntVar(&cfg.Worst, "worst", 10, "The number of the most regressed queries to show per metric")
+	alpha := flag.Float64("alpha", 0.05, "The significance level, below which the p-values are marked with *")
+	metrics := flag.String("metrics", "", "The comma-separated metrics to compare (defaults to all)")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		return errors.New("want two arguments: the per-query files of the baseline and the candidate")
+	}
+
+	baseline, err := readPerQuery(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	candidate, err := readPerQuery(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	comparisons, err := eval.Compare(baseline, candidate, &cfg)
+	if err != nil {
+		return err
+	}
+	if *metrics != "" {
+		comparisons = filter(comparisons, strings.Split(*metrics, ","))
+	}
+
+	mark := func(p float64) string {
+		if p < *alpha {
+			return fmt.Sprintf("%.4f *", p)
+		}
+		return fmt.Sprintf("%.4f", p)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "metric\tbaseline\tcandidate\tdiff\twin/loss/tie\tp(bootstrap)\tp(randomization)")
+	for _, c := range comparisons {
+		fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%+.4f\t%d/%d/%d\t%s\t%s\n",
+			c.Metric, c.Baseline, c.Candidate, c.Diff(),
+			c.Wins, c.Losses, c.Ties,
+			mark(c.BootstrapP), mark(c.RandomizationP))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	for _, c := range comparisons {
+		if len(c.Regressions) == 0 {
+			continue
+		}
+		fmt.Printf("\nWorst regressions on %s:\n", c.Metric)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range c.Regressions {
+			fmt.Fprintf(w, "  %s\t%.4f -> %.4f\t%+.4f\n", r.QueryID, r.Baseline, r.Candidate, r.Diff())
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readPerQuery(name string) (map[string]eval.Metrics, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	perQuery, err := eval.ReadPerQuery(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return perQuery, nil
+}
+
+// filter returns the comparisons of the given metrics, in the given order.
+func filter(comparisons []eval.Comparison, metrics []string) []eval.Comparison {
+	byMetric := make(map[string]eval.Comparison)
+	for _, c := range comparisons {
+		byMetric[c.Metric] = c
+	}
+
+	var filtered []eval.Comparison
+	for _, m := range metrics {
+		if c, ok := byMetric[strings.TrimSpace(m)]; ok {
+			filtered = append(filtered, c)
+		} else {
+			slog.Warn("unknown metric", "metric", m)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,56 @@
+// Package cmdutil holds the helpers shared by the commands.
+package cmdutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-aie/rocketqa"
+)
+
+// FindModelFiles finds the model files in dir. If set, vocabFile overrides
+// the vocab file in dir.
+func FindModelFiles(dir, vocabFile string) (rocketqa.ModelFiles, error) {
+	files, err := rocketqa.FindModelFiles(dir)
+	if err != nil {
+		return files, err
+	}
+	if vocabFile != "" {
+		files.VocabFile = vocabFile
+	}
+	if files.VocabFile == "" {
+		return files, fmt.Errorf(`found no vocab file in %s, use argument "vocab"`, dir)
+	}
+	return files, nil
+}
+
+// ReadFile opens the file name and reads it with read. The errors of read
+// are prefixed by name.
+func ReadFile[T any](name string, read func(r io.Reader) (T, error)) (T, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer f.Close()
+
+	v, err := read(f)
+	if err != nil {
+		return v, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+// WriteFile creates the file name and writes it with write.
+func WriteFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,149 @@
+// Command mine builds training sets for "cli.py train" by mining hard
+// negatives: for every labeled query, the top paragraphs retrieved by a dual
+// encoder that are not known positives. See package mining for details.
+//
+// Usage:
+//
+//	mine -de=models/de -corpus=corpus.tsv -queries=queries.tsv -qrels=qrels.txt [-ce=models/ce -max-score=0.9] -cross=cross.train.tsv -dual=dual.train.tsv
+//
+// The input formats are those of package eval, and the model directories are
+// those accepted by rocketqa.FindModelFiles.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/cmd/internal/cmdutil"
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/mining"
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	corpusFile := flag.String("corpus", "", `The corpus file, with lines of "id\ttitle\tpara" or "id\tpara"`)
+	queriesFile := flag.String("queries", "", `The queries file, with lines of "id\tquery"`)
+	qrelsFile := flag.String("qrels", "", "The relevance judgments file, in TREC or TSV format")
+	deDir := flag.String("de", "", "The directory of the dual encoder model")
+	ceDir := flag.String("ce", "", "The directory of the cross encoder model for filtering false negatives (optional)")
+	vocabFile := flag.String("vocab", "", "The vocab file, if not in the model directories")
+	doLowerCase := flag.Bool("lower", true, "Whether to lowercase the texts")
+	forCN := flag.Bool("cn", true, "Whether the models are for Chinese")
+	queryLen := flag.Int("query-len", 32, "The maximum sequence length of the queries")
+	paraLen := flag.Int("para-len", 384, "The maximum sequence length of the paragraphs")
+	ceLen := flag.Int("ce-len", 384, "The maximum sequence length of the cross encoder")
+	batchSize := flag.Int("batch", 32, "The number of paragraphs per inference when indexing the corpus")
+	var cfg mining.Config
+	flag.IntVar(&cfg.Depth, "depth", 50, "The number of candidates retrieved per query")
+	flag.IntVar(&cfg.Negatives, "negatives", 4, "The maximum number of negatives per query")
+	maxScore := flag.Float64("max-score", 0.9, "The cross encoder probability at or above which a candidate is dropped as a false negative")
+	crossFile := flag.String("cross", "", "The file to write the training set of the cross encoder to")
+	dualFile := flag.String("dual", "", "The file to write the training set of the dual encoder to")
+	flag.Parse()
+
+	if *corpusFile == "" || *queriesFile == "" || *qrelsFile == "" || *deDir == "" {
+		return errors.New(`arguments "corpus", "queries", "qrels" and "de" are required`)
+	}
+	if *crossFile == "" && *dualFile == "" {
+		return errors.New(`at least one of arguments "cross" and "dual" is required`)
+	}
+
+	corpus, err := cmdutil.ReadFile(*corpusFile, eval.ReadCorpus)
+	if err != nil {
+		return err
+	}
+	queries, err := cmdutil.ReadFile(*queriesFile, eval.ReadQueries)
+	if err != nil {
+		return err
+	}
+	qrels, err := cmdutil.ReadFile(*qrelsFile, eval.ReadQrels)
+	if err != nil {
+		return err
+	}
+
+	deFiles, err := cmdutil.FindModelFiles(*deDir, *vocabFile)
+	if err != nil {
+		return err
+	}
+	de, err := rocketqa.NewDualEncoder(&rocketqa.DualEncoderConfig{
+		ModelPath:         deFiles.ModelPath,
+		ParamsPath:        deFiles.ParamsPath,
+		VocabFile:         deFiles.VocabFile,
+		DoLowerCase:       *doLowerCase,
+		QueryMaxSeqLength: *queryLen,
+		ParaMaxSeqLength:  *paraLen,
+		ForCN:             *forCN,
+		Normalize:         true,
+	})
+	if err != nil {
+		return err
+	}
+	defer de.Close()
+
+	if *ceDir != "" {
+		ceFiles, err := cmdutil.FindModelFiles(*ceDir, *vocabFile)
+		if err != nil {
+			return err
+		}
+		ce, err := rocketqa.NewCrossEncoder(&rocketqa.CrossEncoderConfig{
+			ModelPath:    ceFiles.ModelPath,
+			ParamsPath:   ceFiles.ParamsPath,
+			VocabFile:    ceFiles.VocabFile,
+			DoLowerCase:  *doLowerCase,
+			MaxSeqLength: *ceLen,
+			ForCN:        *forCN,
+		})
+		if err != nil {
+			return err
+		}
+		defer ce.Close()
+		cfg.Ranker = ce
+		ms := float32(*maxScore)
+		cfg.MaxScore = &ms
+	}
+
+	slog.Info("indexing", "docs", len(corpus))
+	index := retrieval.NewDenseIndex(de)
+	for start := 0; start < len(corpus); start += *batchSize {
+		if err := index.Add(corpus[start:min(start+*batchSize, len(corpus))]...); err != nil {
+			return err
+		}
+	}
+	cfg.Retriever = index
+
+	slog.Info("mining", "queries", len(queries))
+	examples, stats, err := mining.Mine(context.Background(), &cfg, corpus, queries, qrels)
+	if err != nil {
+		return err
+	}
+	slog.Info("mined",
+		"queries", stats.Queries,
+		"skipped", stats.Skipped,
+		"positives", stats.Positives,
+		"false_negatives", stats.FalseNegatives,
+	)
+
+	if *crossFile != "" {
+		if err := cmdutil.WriteFile(*crossFile, func(w io.Writer) error { return mining.WriteCross(w, examples) }); err != nil {
+			return err
+		}
+	}
+	if *dualFile != "" {
+		if err := cmdutil.WriteFile(*dualFile, func(w io.Writer) error { return mining.WriteDual(w, examples) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
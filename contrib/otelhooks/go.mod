@@ -0,0 +1,25 @@
+module github.com/go-aie/rocketqa/contrib/otelhooks
+
+go 1.21
+
+replace github.com/go-aie/rocketqa => ../../
+
+require (
+	github.com/go-aie/rocketqa v0.0.0-00010101000000-000000000000
+	github.com/google/go-cmp v0.5.9
+	go.opentelemetry.io/otel v1.14.0
+	go.opentelemetry.io/otel/sdk v1.14.0
+	go.opentelemetry.io/otel/trace v1.14.0
+)
+
+require (
+	github.com/go-aie/paddle v0.0.0-20230213030711-67518e191570 // indirect
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/jackc/puddle/v2 v2.2.0 // indirect
+	github.com/paddlepaddle/paddle/paddle/fluid/inference/goapi v0.0.0-20221116023434-3fa7a736e325 // indirect
+	golang.org/x/exp v0.0.0-20230212135524-a684f29349b6 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.5.0 // indirect
+	gonum.org/v1/gonum v0.12.0 // indirect
+)
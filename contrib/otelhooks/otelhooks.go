@@ -0,0 +1,52 @@
+// Package otelhooks traces the encoder calls with OpenTelemetry, by
+// implementing rocketqa.Hooks.
+//
+// It lives in a separate module, so that the core library does not depend
+// on OpenTelemetry.
+package otelhooks
+
+import (
+	"context"
+
+	"github.com/go-aie/rocketqa"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/go-aie/rocketqa/contrib/otelhooks"
+
+// Hooks starts a span named "rocketqa.<op>" for every call, as a child of the
+// span in the context passed to the encoder (if any). The stats of the call
+// are recorded as attributes of the span, with the durations in seconds.
+type Hooks struct {
+	tracer trace.Tracer
+}
+
+// New creates the hooks with the spans created by tp.
+func New(tp trace.TracerProvider) *Hooks {
+	return &Hooks{tracer: tp.Tracer(instrumentationName)}
+}
+
+func (h *Hooks) CallStarted(ctx context.Context, op rocketqa.Op) context.Context {
+	ctx, _ = h.tracer.Start(ctx, "rocketqa."+string(op), trace.WithSpanKind(trace.SpanKindInternal))
+	return ctx
+}
+
+func (h *Hooks) CallFinished(ctx context.Context, stats rocketqa.CallStats) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		attribute.Int("rocketqa.batch_size", stats.BatchSize),
+		attribute.Int("rocketqa.tokens", stats.Tokens),
+		attribute.Int("rocketqa.padded_tokens", stats.PaddedTokens),
+		attribute.Float64("rocketqa.tokenize_seconds", stats.Tokenize.Seconds()),
+		attribute.Float64("rocketqa.pad_seconds", stats.Pad.Seconds()),
+		attribute.Float64("rocketqa.queue_wait_seconds", stats.QueueWait.Seconds()),
+		attribute.Float64("rocketqa.inference_seconds", stats.Inference.Seconds()),
+	)
+	if stats.Err != nil {
+		span.RecordError(stats.Err)
+		span.SetStatus(codes.Error, stats.Err.Error())
+	}
+	span.End()
+}
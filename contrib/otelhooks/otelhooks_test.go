@@ -0,0 +1,72 @@
+package otelhooks_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/contrib/otelhooks"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHooks(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	hooks := otelhooks.New(tp)
+
+	var _ rocketqa.Hooks = hooks
+
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+
+	ctx := hooks.CallStarted(parentCtx, rocketqa.OpEncodePara)
+	hooks.CallFinished(ctx, rocketqa.CallStats{
+		Op:           rocketqa.OpEncodePara,
+		BatchSize:    2,
+		Tokens:       10,
+		PaddedTokens: 16,
+		Inference:    500 * time.Millisecond,
+		Err:          errors.New("len(titles) does not equal len(paras)"),
+	})
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+
+	if want, got := "rocketqa.EncodePara", span.Name(); got != want {
+		t.Errorf("Name: got %q, want %q", got, want)
+	}
+	if want, got := parent.SpanContext().SpanID(), span.Parent().SpanID(); got != want {
+		t.Errorf("Parent: got %v, want %v", got, want)
+	}
+	if want, got := codes.Error, span.Status().Code; got != want {
+		t.Errorf("Status: got %v, want %v", got, want)
+	}
+
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	want := map[string]any{
+		"rocketqa.batch_size":        int64(2),
+		"rocketqa.tokens":            int64(10),
+		"rocketqa.padded_tokens":     int64(16),
+		"rocketqa.inference_seconds": 0.5,
+	}
+	got := make(map[string]any)
+	for k := range want {
+		got[k] = attrs[attribute.Key(k)].AsInterface()
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -0,0 +1,29 @@
+module github.com/go-aie/rocketqa/contrib/promhooks
+
+go 1.21
+
+replace github.com/go-aie/rocketqa => ../../
+
+require (
+	github.com/go-aie/rocketqa v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.14.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-aie/paddle v0.0.0-20230213030711-67518e191570 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/jackc/puddle/v2 v2.2.0 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/paddlepaddle/paddle/paddle/fluid/inference/goapi v0.0.0-20221116023434-3fa7a736e325 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	golang.org/x/exp v0.0.0-20230212135524-a684f29349b6 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+	gonum.org/v1/gonum v0.12.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
@@ -0,0 +1,126 @@
+// Package promhooks exports the metrics of the encoders to Prometheus, by
+// implementing rocketqa.Hooks.
+//
+// It lives in a separate module, so that the core library does not depend
+// on the Prometheus client.
+package promhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type Config struct {
+	// The namespace of the metrics. Defaults to "rocketqa".
+	Namespace string
+	// The buckets of the latency histograms, in seconds. Defaults to
+	// exponential buckets from 1ms to about 16s.
+	LatencyBuckets []float64
+	// The buckets of the batch size histogram. Defaults to powers of two
+	// from 1 to 1024.
+	BatchSizeBuckets []float64
+}
+
+// Hooks records the following metrics, all labeled by the operation:
+//
+//   - <namespace>_calls_total{op, status}: the number of calls, where
+//     status is "ok" or "error".
+//   - <namespace>_phase_duration_seconds{op, phase}: the latency of each
+//     phase, which is one of "tokenize", "pad", "queue_wait", "inference"
+//     and "total".
+//   - <namespace>_batch_size{op}: the number of inputs per call.
+//   - <namespace>_tokens_total{op, kind}: the number of tokens, where kind
+//     is "real" or "padded".
+type Hooks struct {
+	calls     *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	batchSize *prometheus.HistogramVec
+	tokens    *prometheus.CounterVec
+}
+
+// New creates the hooks and registers the metrics with reg.
+func New(reg prometheus.Registerer, cfg *Config) (*Hooks, error) {
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = "rocketqa"
+	}
+	latencyBuckets := cfg.LatencyBuckets
+	if latencyBuckets == nil {
+		latencyBuckets = prometheus.ExponentialBuckets(0.001, 2, 15)
+	}
+	batchSizeBuckets := cfg.BatchSizeBuckets
+	if batchSizeBuckets == nil {
+		batchSizeBuckets = prometheus.ExponentialBuckets(1, 2, 11)
+	}
+
+	h := &Hooks{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "calls_total",
+			Help:      "The number of encoder calls.",
+		}, []string{"op", "status"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "The latency of each phase of the encoder calls.",
+			Buckets:   latencyBuckets,
+		}, []string{"op", "phase"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size",
+			Help:      "The number of inputs per encoder call.",
+			Buckets:   batchSizeBuckets,
+		}, []string{"op"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tokens_total",
+			Help:      "The number of tokens fed to the models, without (real) and with (padded) padding.",
+		}, []string{"op", "kind"}),
+	}
+
+	for _, c := range []prometheus.Collector{h.calls, h.durations, h.batchSize, h.tokens} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func (h *Hooks) CallStarted(ctx context.Context, op rocketqa.Op) context.Context {
+	return ctx
+}
+
+func (h *Hooks) CallFinished(ctx context.Context, stats rocketqa.CallStats) {
+	op := string(stats.Op)
+
+	status := "ok"
+	if stats.Err != nil {
+		status = "error"
+	}
+	h.calls.WithLabelValues(op, status).Inc()
+
+	h.batchSize.WithLabelValues(op).Observe(float64(stats.BatchSize))
+	if stats.Err != nil {
+		// The phases and the tokens of failed calls are incomplete.
+		return
+	}
+
+	for _, p := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"tokenize", stats.Tokenize},
+		{"pad", stats.Pad},
+		{"queue_wait", stats.QueueWait},
+		{"inference", stats.Inference},
+		{"total", stats.Duration},
+	} {
+		h.durations.WithLabelValues(op, p.name).Observe(p.duration.Seconds())
+	}
+
+	h.tokens.WithLabelValues(op, "real").Add(float64(stats.Tokens))
+	h.tokens.WithLabelValues(op, "padded").Add(float64(stats.PaddedTokens))
+}
@@ -0,0 +1,58 @@
+package promhooks_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/contrib/promhooks"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHooks(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	hooks, err := promhooks.New(reg, &promhooks.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var _ rocketqa.Hooks = hooks
+
+	ctx := hooks.CallStarted(context.Background(), rocketqa.OpRank)
+	hooks.CallFinished(ctx, rocketqa.CallStats{
+		Op:           rocketqa.OpRank,
+		BatchSize:    4,
+		Tokens:       30,
+		PaddedTokens: 40,
+		Inference:    20 * time.Millisecond,
+		Duration:     25 * time.Millisecond,
+	})
+	hooks.CallFinished(ctx, rocketqa.CallStats{
+		Op:        rocketqa.OpRank,
+		BatchSize: 2,
+		Err:       errors.New("len(paras) does not equal len(queries)"),
+	})
+
+	want := `
+# HELP rocketqa_calls_total The number of encoder calls.
+# TYPE rocketqa_calls_total counter
+rocketqa_calls_total{op="Rank",status="error"} 1
+rocketqa_calls_total{op="Rank",status="ok"} 1
+# HELP rocketqa_tokens_total The number of tokens fed to the models, without (real) and with (padded) padding.
+# TYPE rocketqa_tokens_total counter
+rocketqa_tokens_total{kind="padded",op="Rank"} 40
+rocketqa_tokens_total{kind="real",op="Rank"} 30
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "rocketqa_calls_total", "rocketqa_tokens_total"); err != nil {
+		t.Error(err)
+	}
+
+	// One observation per phase, from the successful call only.
+	if got := testutil.CollectAndCount(reg, "rocketqa_phase_duration_seconds"); got != 5 {
+		t.Errorf("got %d phase series, want 5", got)
+	}
+}
@@ -1,7 +1,9 @@
 package rocketqa
 
 import (
+	"context"
 	"fmt"
+	"math"
 
 	"github.com/go-aie/paddle"
 	"github.com/go-aie/rocketqa/internal"
@@ -13,64 +15,368 @@ type CrossEncoderConfig struct {
 	DoLowerCase           bool
 	MaxSeqLength          int
 	ForCN                 bool
+	// The title of the paras without titles, i.e. when the titles are
+	// omitted or empty. Defaults to none. See
+	// DualEncoderConfig.TitlePlaceholder.
+	TitlePlaceholder string
 	// The maximum number of predictors for concurrent inferences.
 	// Defaults to the value of runtime.NumCPU.
 	MaxConcurrency int
+	// Whether the methods fail with ErrInputTooLong, instead of truncating
+	// the (query, para, title) triples longer than MaxSeqLength.
+	RejectTooLong bool
+	// How to derive the scores from the model output. Defaults to
+	// ScoreProbability.
+	Score ScoreType
+	// An optional calibrator that maps the relevance logits to calibrated
+	// probabilities (see FitTemperature and FitPlatt). If set, Score is
+	// ignored.
+	Calibrator Calibrator
+	// Whether to run synthetic inputs at the maximum sequence length through
+	// every predictor in the pool before NewCrossEncoder returns, to keep
+	// the first requests from being slow. See WarmUpReport for the results.
+	WarmUp bool
+	// Optional hooks for instrumentation, e.g. metrics and tracing.
+	Hooks Hooks
 }
 
+// ScoreType specifies how to derive the scores from the model output.
+//
+// Models trained with joint_training == 0 output the probabilities of the
+// two classes (irrelevant, relevant), in which case the relevance logit is
+// log(p1/p0). Models trained with joint_training == 1 output the relevance
+// logit directly, as the only column.
+type ScoreType int
+
+const (
+	// ScoreProbability is the probability of relevance.
+	ScoreProbability ScoreType = iota
+	// ScoreLogit is the relevance logit, which is unbounded.
+	ScoreLogit
+)
+
 type CrossEncoder struct {
-	engine    *paddle.Engine
-	generator *internal.Generator
+	engine     *engine
+	generator  *internal.Generator
+	scoreType  ScoreType
+	calibrator Calibrator
+	hooks      Hooks
+
+	rejectTooLong bool
+
+	fingerprint  string
+	warmUpReport WarmUpReport
 }
 
 func NewCrossEncoder(cfg *CrossEncoderConfig) (*CrossEncoder, error) {
+	if c, ok := cfg.Calibrator.(interface{ validate() error }); ok {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	generator, err := internal.NewGenerator(internal.GeneratorConfig{
-		VocabFile:    cfg.VocabFile,
-		DoLowerCase:  cfg.DoLowerCase,
-		MaxSeqLength: cfg.MaxSeqLength,
-		ForCN:        cfg.ForCN,
+		VocabFile:        cfg.VocabFile,
+		DoLowerCase:      cfg.DoLowerCase,
+		MaxSeqLength:     cfg.MaxSeqLength,
+		ForCN:            cfg.ForCN,
+		TitlePlaceholder: cfg.TitlePlaceholder,
 	})
 	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVocab, err)
+	}
+	if err := checkModelFiles(cfg.ModelPath, cfg.ParamsPath); err != nil {
 		return nil, err
 	}
 
-	return &CrossEncoder{
-		engine:    paddle.NewEngine(cfg.ModelPath, cfg.ParamsPath, cfg.MaxConcurrency),
-		generator: generator,
-	}, nil
+	fingerprint, err := modelFingerprint(
+		[]string{cfg.ModelPath, cfg.ParamsPath, cfg.VocabFile},
+		cfg.DoLowerCase, cfg.MaxSeqLength, cfg.ForCN, cfg.TitlePlaceholder,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	engine, err := newEngine(cfg.ModelPath, cfg.ParamsPath, cfg.MaxConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	ce := &CrossEncoder{
+		engine:      engine,
+		generator:   generator,
+		scoreType:   cfg.Score,
+		calibrator:  cfg.Calibrator,
+		fingerprint: fingerprint,
+	}
+
+	if cfg.WarmUp {
+		query, para := warmUpPair(cfg.MaxSeqLength)
+		ce.warmUpReport, err = warmUp(engine, func() error {
+			_, err := ce.Rank([]string{query}, []string{para}, []string{warmUpTitle})
+			return err
+		})
+		if err != nil {
+			ce.Close()
+			return nil, err
+		}
+	}
+	// Set after the warm-up, whose calls are not reported to the hooks.
+	ce.hooks = cfg.Hooks
+	ce.rejectTooLong = cfg.RejectTooLong
+
+	return ce, nil
 }
 
+// Close releases the predictors, after waiting for the in-flight inferences
+// to finish. Later calls fail with ErrClosed. It always returns nil.
+func (ce *CrossEncoder) Close() error {
+	ce.engine.close()
+	return nil
+}
+
+// WarmUpReport returns the results of the warm-up, which is zero if
+// CrossEncoderConfig.WarmUp is not set.
+func (ce *CrossEncoder) WarmUpReport() WarmUpReport {
+	return ce.warmUpReport
+}
+
+// Fingerprint returns a string identifying the model and the settings that
+// affect the model output. See DualEncoder.Fingerprint for details.
+func (ce *CrossEncoder) Fingerprint() string {
+	return ce.fingerprint
+}
+
+// Rank returns the relevance scores of the (query, para, title) triples, as
+// configured by CrossEncoderConfig.Score and CrossEncoderConfig.Calibrator.
+// The titles are optional: titles is either empty or of the same length as
+// queries.
 func (ce *CrossEncoder) Rank(queries, paras, titles []string) ([]float32, error) {
-	n := len(queries)
-	if n == 0 {
+	return ce.RankContext(context.Background(), queries, paras, titles)
+}
+
+// RankContext is like Rank, but passes ctx to the hooks (see WithRequestID).
+func (ce *CrossEncoder) RankContext(ctx context.Context, queries, paras, titles []string) (scores []float32, err error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+
+	c := startCall(ctx, ce.hooks, OpRank, len(queries))
+	defer c.end(&err)
+
+	rows, err := ce.infer(c, queries, paras, titles)
+	if err != nil {
+		return nil, err
+	}
+	return ce.scores(rows), nil
+}
+
+// RankWithThreshold is like Rank, but only keeps the triples whose scores
+// are not less than threshold. It returns the indices of the kept triples,
+// in their original order, along with their scores.
+//
+// For the threshold to mean the same thing across models, use a Calibrator.
+func (ce *CrossEncoder) RankWithThreshold(queries, paras, titles []string, threshold float32) (indices []int, scores []float32, err error) {
+	all, err := ce.Rank(queries, paras, titles)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, score := range all {
+		if score >= threshold {
+			indices = append(indices, i)
+			scores = append(scores, score)
+		}
+	}
+	return indices, scores, nil
+}
+
+// TokenizedPara is a (para, title) pair tokenized in advance by
+// CrossEncoder.TokenizePara, which can be cached and reused across queries.
+// It is only valid for cross encoders with the same vocabulary and
+// tokenization settings (DoLowerCase and ForCN).
+type TokenizedPara struct {
+	TokenIDs []int64
+}
+
+// TokenizePara tokenizes the para and its title (which can be empty, see
+// CrossEncoderConfig.TitlePlaceholder).
+func (ce *CrossEncoder) TokenizePara(para, title string) TokenizedPara {
+	return TokenizedPara{TokenIDs: ce.generator.ParaIDs(para, title)}
+}
+
+// RankQuery is like Rank, but scores a single query against all the (para,
+// title) pairs, and only tokenizes the query once.
+func (ce *CrossEncoder) RankQuery(query string, paras, titles []string) ([]float32, error) {
+	return ce.RankQueryContext(context.Background(), query, paras, titles)
+}
+
+// RankQueryContext is like RankQuery, but passes ctx to the hooks (see
+// WithRequestID).
+func (ce *CrossEncoder) RankQueryContext(ctx context.Context, query string, paras, titles []string) (scores []float32, err error) {
+	if len(paras) == 0 {
+		return nil, nil
+	}
+
+	c := startCall(ctx, ce.hooks, OpRank, len(paras))
+	defer c.end(&err)
+
+	if err := checkTitles(paras, titles); err != nil {
+		return nil, err
+	}
+
+	tokenized := make([]TokenizedPara, len(paras))
+	for i, p := range paras {
+		tokenized[i] = ce.TokenizePara(p, titleAt(titles, i))
+	}
+	return ce.rankTokenized(c, query, tokenized)
+}
+
+// RankTokenized is like RankQuery, but accepts the paras tokenized in
+// advance by TokenizePara.
+func (ce *CrossEncoder) RankTokenized(query string, paras []TokenizedPara) (scores []float32, err error) {
+	if len(paras) == 0 {
 		return nil, nil
 	}
-	if len(paras) != n {
-		return nil, fmt.Errorf("len(paras) does not equal len(queries)")
+
+	c := startCall(context.Background(), ce.hooks, OpRank, len(paras))
+	defer c.end(&err)
+
+	return ce.rankTokenized(c, query, paras)
+}
+
+func (ce *CrossEncoder) rankTokenized(c *call, query string, paras []TokenizedPara) ([]float32, error) {
+	queryIDs := ce.generator.QueryIDs(query)
+	var records []internal.Record
+	for _, p := range paras {
+		records = append(records, ce.generator.GenerateCEFromIDs(queryIDs, p.TokenIDs))
 	}
-	if len(titles) > 0 && len(titles) != n {
-		return nil, fmt.Errorf("len(titles) does not equal len(queries)")
+
+	rows, err := ce.run(c, records)
+	if err != nil {
+		return nil, err
+	}
+	return ce.scores(rows), nil
+}
+
+// Logits returns the uncalibrated relevance logits of the (query, para,
+// title) triples, which are the inputs for fitting a Calibrator.
+func (ce *CrossEncoder) Logits(queries, paras, titles []string) (logits []float32, err error) {
+	if len(queries) == 0 {
+		return nil, nil
+	}
+
+	c := startCall(context.Background(), ce.hooks, OpLogits, len(queries))
+	defer c.end(&err)
+
+	rows, err := ce.infer(c, queries, paras, titles)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		logits = append(logits, relevanceLogit(row))
+	}
+	return logits, nil
+}
+
+// infer runs the model and returns the rows of its output.
+func (ce *CrossEncoder) infer(c *call, queries, paras, titles []string) ([][]float32, error) {
+	if err := checkTriples(queries, paras, titles); err != nil {
+		return nil, err
 	}
 
 	var records []internal.Record
-	for i := 0; i < n; i++ {
-		e := &internal.Example{
+	for i := range queries {
+		records = append(records, ce.generator.GenerateCE(&internal.Example{
 			Query: queries[i],
+			Title: titleAt(titles, i),
 			Para:  paras[i],
+		}))
+	}
+
+	return ce.run(c, records)
+}
+
+// run runs the model on the records and returns the rows of its output,
+// recording the stats in c.
+func (ce *CrossEncoder) run(c *call, records []internal.Record) ([][]float32, error) {
+	if ce.rejectTooLong {
+		for i, r := range records {
+			if r.Truncated > 0 {
+				// The query, the para and the title are only too long
+				// together, so none of them is to blame alone.
+				return nil, &InputError{Arg: "inputs", Index: i, Err: ErrInputTooLong}
+			}
 		}
-		if len(titles) > 0 {
-			e.Title = titles[i]
-		}
-		records = append(records, ce.generator.GenerateCE(e))
 	}
+	c.mark(phaseTokenize)
 
 	inputs := ce.getInputs(records)
-	outputs := ce.engine.Infer(inputs)
+	if c != nil {
+		var tokens, truncated int
+		for _, r := range records {
+			tokens += len(r.TokenIDs)
+			if r.Truncated > 0 {
+				truncated++
+			}
+		}
+		c.addTokens(tokens, numElements(inputs[0]), truncated)
+	}
+	c.mark(phasePad)
+
+	outputs, queueWait, inference, err := ce.engine.infer(inputs)
+	c.addInference(queueWait, inference)
+	if err != nil {
+		return nil, err
+	}
 
 	// We only care the first (also the only one) output.
+	if err := checkOutputs(outputs, 1, len(records)); err != nil {
+		return nil, err
+	}
 	result := outputs[0]
-	// Extract the second column (Assume that joint_training == 0)
-	return paddle.NewMatrix[float32](result).Col(1), nil
+	return paddle.NewMatrix[float32](result).Rows(), nil
+}
+
+// scores derives the scores from the rows of the model output.
+func (ce *CrossEncoder) scores(rows [][]float32) []float32 {
+	var scores []float32
+	for _, row := range rows {
+		scores = append(scores, ce.score(row))
+	}
+	return scores
+}
+
+// score derives the score from one row of the model output.
+func (ce *CrossEncoder) score(row []float32) float32 {
+	if ce.calibrator != nil {
+		return ce.calibrator.Calibrate(relevanceLogit(row))
+	}
+
+	switch {
+	case ce.scoreType == ScoreLogit:
+		return relevanceLogit(row)
+	case len(row) == 1:
+		return sigmoid(row[0])
+	default:
+		// Return the probability as is (instead of sigmoid(logit))
+		// to avoid any loss of precision.
+		return row[1]
+	}
+}
+
+// relevanceLogit returns the relevance logit from one row of the model
+// output. See ScoreType for details.
+func relevanceLogit(row []float32) float32 {
+	if len(row) == 1 {
+		return row[0]
+	}
+
+	// Clamp the probabilities to avoid infinite logits.
+	const eps = 1e-7
+	p0 := math.Max(float64(row[0]), eps)
+	p1 := math.Max(float64(row[1]), eps)
+	return float32(math.Log(p1) - math.Log(p0))
 }
 
 func (ce *CrossEncoder) getInputs(records []internal.Record) []paddle.Tensor {
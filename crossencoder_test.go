@@ -1,10 +1,13 @@
 package rocketqa_test
 
 import (
+	"errors"
+	"math"
 	"testing"
 
 	"github.com/go-aie/rocketqa"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestCrossEncoder_Rank(t *testing.T) {
@@ -43,6 +46,126 @@ func TestCrossEncoder_Rank(t *testing.T) {
 	}
 }
 
+func TestCrossEncoder_RankWithThreshold(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inQPTs := rocketqa.QPTs{
+		{
+			Query: "你好，世界！",
+			Para:  "这是一段较长的文本。",
+		},
+		{
+			Query: "Hello, World!",
+			Para:  "This is a long paragraph.",
+		},
+	}
+
+	gotIndices, gotScores, _ := ce.RankWithThreshold(inQPTs.Q(), inQPTs.P(), inQPTs.T(), 0.06)
+	wantIndices, wantScores := []int{1}, []float32{0.07384859}
+	if !cmp.Equal(gotIndices, wantIndices) {
+		diff := cmp.Diff(gotIndices, wantIndices)
+		t.Errorf("Indices (Want - Got): %s", diff)
+	}
+	if !cmp.Equal(gotScores, wantScores) {
+		diff := cmp.Diff(gotScores, wantScores)
+		t.Errorf("Scores (Want - Got): %s", diff)
+	}
+}
+
+func TestCrossEncoder_RankQuery(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := "你好，世界！"
+	paras := []string{"这是一段较长的文本。", "This is a long paragraph."}
+	titles := []string{"", "标题"}
+
+	wantScores, err := ce.Rank([]string{query, query}, paras, titles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotScores, err := ce.RankQuery(query, paras, titles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(gotScores, wantScores) {
+		diff := cmp.Diff(gotScores, wantScores)
+		t.Errorf("RankQuery (Want - Got): %s", diff)
+	}
+
+	tokenized := []rocketqa.TokenizedPara{
+		ce.TokenizePara(paras[0], titles[0]),
+		ce.TokenizePara(paras[1], titles[1]),
+	}
+	gotScores, err = ce.RankTokenized(query, tokenized)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(gotScores, wantScores) {
+		diff := cmp.Diff(gotScores, wantScores)
+		t.Errorf("RankTokenized (Want - Got): %s", diff)
+	}
+}
+
+func TestCrossEncoder_Calibrator(t *testing.T) {
+	cfg := newCrossEncoderConfig(1)
+	cfg.Calibrator = rocketqa.TemperatureScaling{T: 1}
+	ce, err := rocketqa.NewCrossEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inQPTs := rocketqa.QPTs{
+		{
+			Query: "你好，世界！",
+			Para:  "这是一段较长的文本。",
+		},
+	}
+
+	// Logits are log(p1/p0) of the probabilities.
+	gotLogits, _ := ce.Logits(inQPTs.Q(), inQPTs.P(), inQPTs.T())
+	wantLogits := []float32{float32(math.Log(0.05041640 / (1 - 0.05041640)))}
+	opt := cmpopts.EquateApprox(0, 1e-4)
+	if !cmp.Equal(gotLogits, wantLogits, opt) {
+		diff := cmp.Diff(gotLogits, wantLogits)
+		t.Errorf("Logits (Want - Got): %s", diff)
+	}
+
+	// Temperature scaling with T=1 recovers the probabilities.
+	gotScores, _ := ce.Rank(inQPTs.Q(), inQPTs.P(), inQPTs.T())
+	wantScores := []float32{0.05041640}
+	if !cmp.Equal(gotScores, wantScores, opt) {
+		diff := cmp.Diff(gotScores, wantScores)
+		t.Errorf("Scores (Want - Got): %s", diff)
+	}
+}
+
+func TestCrossEncoder_WarmUp(t *testing.T) {
+	cfg := newCrossEncoderConfig(2)
+	cfg.WarmUp = true
+	ce, err := rocketqa.NewCrossEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := ce.WarmUpReport()
+	if report.Runs != 2 {
+		t.Errorf("Runs: Got (%v) != Want (2)", report.Runs)
+	}
+	if report.Predictors < 1 || report.Predictors > report.Runs {
+		t.Errorf("Predictors: Got (%v), want in [1, %v]", report.Predictors, report.Runs)
+	}
+	if report.Duration <= 0 || report.MaxLatency <= 0 || report.MaxLatency > report.Duration {
+		t.Errorf("Got invalid latencies: %+v", report)
+	}
+}
+
 func BenchmarkCrossEncoder_Rank(b *testing.B) {
 	inQPTs := rocketqa.QPTs{
 		{
@@ -84,7 +207,11 @@ func BenchmarkCrossEncoder_Rank(b *testing.B) {
 }
 
 func newCrossEncoder(maxConcurrency int) (*rocketqa.CrossEncoder, error) {
-	return rocketqa.NewCrossEncoder(&rocketqa.CrossEncoderConfig{
+	return rocketqa.NewCrossEncoder(newCrossEncoderConfig(maxConcurrency))
+}
+
+func newCrossEncoderConfig(maxConcurrency int) *rocketqa.CrossEncoderConfig {
+	return &rocketqa.CrossEncoderConfig{
 		ModelPath:      "./testdata/zh_dureader_ce_v2.pdmodel",
 		ParamsPath:     "./testdata/zh_dureader_ce_v2.pdiparams",
 		VocabFile:      "./testdata/zh_vocab.txt",
@@ -92,5 +219,22 @@ func newCrossEncoder(maxConcurrency int) (*rocketqa.CrossEncoder, error) {
 		MaxSeqLength:   384,
 		ForCN:          true,
 		MaxConcurrency: maxConcurrency,
-	})
+	}
+}
+
+func TestCrossEncoder_Close(t *testing.T) {
+	ce, err := newCrossEncoder(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ce.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ce.Rank([]string{"你好"}, []string{"世界"}, nil); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("Rank: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
+	if _, err := ce.RankQuery("你好", []string{"世界"}, nil); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("RankQuery: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
 }
@@ -0,0 +1,282 @@
+// Package diskcache implements a persistent cache of paragraph embeddings,
+// stored on local disk in a single bbolt file.
+//
+// The cache is content-addressed: the key of a paragraph is the hash of the
+// encoder fingerprint, the title and the paragraph. After small edits to a
+// corpus, re-encoding the whole corpus through the cache only runs inference
+// for the new or changed paragraphs.
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-aie/rocketqa/vecmath"
+	bolt "go.etcd.io/bbolt"
+)
+
+// formatVersion is bumped whenever the layout of the file changes.
+const formatVersion = 1
+
+// ErrLengthMismatch is returned by EncodePara if the titles do not pair with
+// the paragraphs. It is the same as rocketqa.ErrLengthMismatch in meaning,
+// but defined here to keep the package free of cgo.
+var ErrLengthMismatch = errors.New("length mismatch")
+
+var (
+	metaBucket    = []byte("meta")
+	vectorsBucket = []byte("vectors")
+	versionKey    = []byte("version")
+)
+
+// ParaEncoder is implemented by rocketqa.DualEncoder.
+type ParaEncoder interface {
+	EncodePara(paras, titles []string) ([]vecmath.Vector, error)
+	Fingerprint() string
+}
+
+type Config struct {
+	// The path of the cache file, which is created if it does not exist.
+	Path string
+	// The encoder for the paragraphs that are not in the cache.
+	Encoder ParaEncoder
+	// The maximum number of paragraphs per call of Encoder.EncodePara.
+	// Defaults to 32.
+	BatchSize int
+}
+
+// Stats holds the counters of a Cache, in number of paragraphs.
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+// HitRate returns the fraction of paragraphs served from the cache.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Cache is a ParaEncoder whose outputs are persisted on disk. It is safe for
+// concurrent use within one process, and the file can only be opened by one
+// process at a time.
+type Cache struct {
+	db          *bolt.DB
+	encoder     ParaEncoder
+	fingerprint string
+	batchSize   int
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Open opens the cache file, creating it if necessary.
+func Open(cfg *Config) (*Cache, error) {
+	if cfg.Encoder == nil {
+		return nil, errors.New("Encoder is required")
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+
+	db, err := bolt.Open(cfg.Path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(vectorsBucket); err != nil {
+			return err
+		}
+
+		if v := meta.Get(versionKey); v != nil {
+			if version := binary.LittleEndian.Uint64(v); version != formatVersion {
+				return fmt.Errorf("unsupported cache format version %d", version)
+			}
+			return nil
+		}
+		return meta.Put(versionKey, binary.LittleEndian.AppendUint64(nil, formatVersion))
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Cache{
+		db:          db,
+		encoder:     cfg.Encoder,
+		fingerprint: cfg.Encoder.Fingerprint(),
+		batchSize:   batchSize,
+	}, nil
+}
+
+// Close closes the cache file.
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Fingerprint returns the fingerprint of the underlying encoder.
+func (c *Cache) Fingerprint() string {
+	return c.fingerprint
+}
+
+// EncodePara returns the vectors of the paragraphs, only running inference
+// for the ones that are not in the cache. Newly computed vectors are written
+// to disk after every batch, so the work done is kept even if a later batch
+// fails.
+func (c *Cache) EncodePara(paras, titles []string) ([]vecmath.Vector, error) {
+	n := len(paras)
+	if n == 0 {
+		return nil, nil
+	}
+	// The titles are optional, as in rocketqa.DualEncoder.EncodePara.
+	switch len(titles) {
+	case 0:
+		titles = make([]string, n)
+	case n:
+	default:
+		return nil, fmt.Errorf("%w: len(titles) = %d does not equal len(paras) = %d", ErrLengthMismatch, len(titles), n)
+	}
+
+	keys := make([][]byte, n)
+	for i := range paras {
+		keys[i] = c.key(titles[i], paras[i])
+	}
+
+	vectors := make([]vecmath.Vector, n)
+	// missing maps the keys of the missing paragraphs to their indices, so
+	// that duplicates are only encoded once.
+	missing := make(map[string][]int)
+	var order []string
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(vectorsBucket)
+		for i, k := range keys {
+			if v := b.Get(k); v != nil {
+				vectors[i] = decode(v)
+				continue
+			}
+			if _, ok := missing[string(k)]; !ok {
+				order = append(order, string(k))
+			}
+			missing[string(k)] = append(missing[string(k)], i)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	numMissing := 0
+	for _, indices := range missing {
+		numMissing += len(indices)
+	}
+	c.hits.Add(int64(n - numMissing))
+	c.misses.Add(int64(numMissing))
+
+	for start := 0; start < len(order); start += c.batchSize {
+		end := start + c.batchSize
+		if end > len(order) {
+			end = len(order)
+		}
+		batch := order[start:end]
+
+		batchParas := make([]string, len(batch))
+		batchTitles := make([]string, len(batch))
+		for j, k := range batch {
+			i := missing[k][0]
+			batchParas[j], batchTitles[j] = paras[i], titles[i]
+		}
+
+		encoded, err := c.encoder.EncodePara(batchParas, batchTitles)
+		if err != nil {
+			return nil, err
+		}
+		if len(encoded) != len(batch) {
+			return nil, fmt.Errorf("got %d vectors, want %d", len(encoded), len(batch))
+		}
+
+		err = c.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(vectorsBucket)
+			for j, k := range batch {
+				if err := b.Put([]byte(k), encode(encoded[j])); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// The duplicates get copies, so that the vectors can be modified
+		// independently, as those of rocketqa.EmbeddingCache.
+		for j, k := range batch {
+			indices := missing[k]
+			vectors[indices[0]] = encoded[j]
+			for _, i := range indices[1:] {
+				vectors[i] = append(vecmath.Vector(nil), encoded[j]...)
+			}
+		}
+	}
+
+	return vectors, nil
+}
+
+// Stats returns a snapshot of the counters since the cache was opened.
+func (c *Cache) Stats() Stats {
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+// Len returns the number of vectors in the cache file.
+func (c *Cache) Len() (int, error) {
+	var n int
+	err := c.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(vectorsBucket).Stats().KeyN
+		return nil
+	})
+	return n, err
+}
+
+// key returns the hash of the fingerprint, the title and the paragraph. Each
+// field is prefixed by its length to avoid ambiguity.
+func (c *Cache) key(title, para string) []byte {
+	h := sha256.New()
+	for _, s := range []string{c.fingerprint, title, para} {
+		h.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(s))))
+		h.Write([]byte(s))
+	}
+	return h.Sum(nil)
+}
+
+func encode(v vecmath.Vector) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+// decode copies the vector out of b, which is only valid in the transaction.
+func decode(b []byte) vecmath.Vector {
+	v := make(vecmath.Vector, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+package diskcache_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-aie/rocketqa/diskcache"
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeEncoder encodes a paragraph into a vector of its length and its title
+// length, and records the paragraphs it has encoded.
+type fakeEncoder struct {
+	fingerprint string
+	encoded     [][]string
+}
+
+func (e *fakeEncoder) EncodePara(paras, titles []string) ([]vecmath.Vector, error) {
+	e.encoded = append(e.encoded, paras)
+	var vectors []vecmath.Vector
+	for i, p := range paras {
+		vectors = append(vectors, vecmath.Vector{float32(len(p)), float32(len(titles[i]))})
+	}
+	return vectors, nil
+}
+
+func (e *fakeEncoder) Fingerprint() string {
+	return e.fingerprint
+}
+
+func TestCache_EncodePara(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+
+	tests := []struct {
+		name        string
+		fingerprint string
+		inParas     []string
+		wantEncoded [][]string
+		wantStats   diskcache.Stats
+	}{
+		{
+			name:        "cold",
+			fingerprint: "a",
+			inParas:     []string{"p1", "p22", "p1", "p333"},
+			wantEncoded: [][]string{{"p1", "p22"}, {"p333"}},
+			wantStats:   diskcache.Stats{Misses: 4},
+		},
+		{
+			name:        "warm",
+			fingerprint: "a",
+			inParas:     []string{"p1", "p22", "p1", "p333"},
+			wantStats:   diskcache.Stats{Hits: 4},
+		},
+		{
+			name:        "edited",
+			fingerprint: "a",
+			inParas:     []string{"p1", "p22", "p4444"},
+			wantEncoded: [][]string{{"p4444"}},
+			wantStats:   diskcache.Stats{Hits: 2, Misses: 1},
+		},
+		{
+			name:        "another model",
+			fingerprint: "b",
+			inParas:     []string{"p1"},
+			wantEncoded: [][]string{{"p1"}},
+			wantStats:   diskcache.Stats{Misses: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := &fakeEncoder{fingerprint: tt.fingerprint}
+			cache, err := diskcache.Open(&diskcache.Config{
+				Path:      path,
+				Encoder:   enc,
+				BatchSize: 2,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cache.Close()
+
+			titles := make([]string, len(tt.inParas))
+			gotVectors, err := cache.EncodePara(tt.inParas, titles)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantVectors, _ := (&fakeEncoder{}).EncodePara(tt.inParas, titles)
+			if !cmp.Equal(gotVectors, wantVectors) {
+				diff := cmp.Diff(gotVectors, wantVectors)
+				t.Errorf("Vectors (Want - Got): %s", diff)
+			}
+			if !cmp.Equal(enc.encoded, tt.wantEncoded) {
+				diff := cmp.Diff(enc.encoded, tt.wantEncoded)
+				t.Errorf("Encoded (Want - Got): %s", diff)
+			}
+			if !cmp.Equal(cache.Stats(), tt.wantStats) {
+				diff := cmp.Diff(cache.Stats(), tt.wantStats)
+				t.Errorf("Stats (Want - Got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestCache_Title(t *testing.T) {
+	enc := &fakeEncoder{}
+	cache, err := diskcache.Open(&diskcache.Config{
+		Path:    filepath.Join(t.TempDir(), "cache.db"),
+		Encoder: enc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	// The same paragraph with different titles are different entries.
+	if _, err := cache.EncodePara([]string{"p", "p"}, []string{"t1", "t22"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cache.Len(); got != 2 {
+		t.Errorf("got %d entries, want 2", got)
+	}
+
+	if _, err := cache.EncodePara([]string{"p", "p"}, []string{"t1"}); !errors.Is(err, diskcache.ErrLengthMismatch) {
+		t.Errorf("got error %v, want %v", err, diskcache.ErrLengthMismatch)
+	}
+}
+
+func TestCache_Duplicates(t *testing.T) {
+	cache, err := diskcache.Open(&diskcache.Config{
+		Path:    filepath.Join(t.TempDir(), "cache.db"),
+		Encoder: &fakeEncoder{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	vectors, err := cache.EncodePara([]string{"p1", "p1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modifying the vector of a paragraph does not change its duplicate.
+	vectors[0][0] = 100
+	want := vecmath.Vector{2, 0}
+	if !cmp.Equal(vectors[1], want) {
+		diff := cmp.Diff(want, vectors[1])
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -0,0 +1,264 @@
+// Package distill labels the (query, paragraph) pairs of a retrieval run with
+// the scores of the cross encoder, which are the soft labels for distilling
+// the dual encoder (as in the denoised training of RocketQA).
+//
+// Large jobs can be split into shards by query, which can run on different
+// machines, and an interrupted job resumes where it stopped.
+package distill
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+type Config struct {
+	// The ranker whose scores are the labels, such as
+	// *rocketqa.CrossEncoder. Required.
+	Ranker retrieval.Ranker
+	// The path of the output file, with lines of
+	// "query_id\tdoc_id\tquery\ttitle\tpara\tscore". The progress is
+	// recorded in the file at Path+".progress", for resuming. Required.
+	Path string
+	// The shard to label, in [0, Shards), and the number of shards. Every
+	// query belongs to one shard, chosen by the hash of its ID. Shards
+	// defaults to 1.
+	Shard, Shards int
+	// The maximum number of candidates per query, which are the top ranked
+	// ones in the run. Zero means all.
+	Depth int
+	// The number of pairs per call of Ranker. Defaults to 32.
+	BatchSize int
+}
+
+// Stats holds the counters of a labeling job.
+type Stats struct {
+	// The numbers of the queries and the pairs labeled by this job.
+	Queries, Pairs int
+	// The number of queries skipped, because they were labeled before an
+	// interruption.
+	Resumed int
+	// The number of the candidates skipped, because they are not in the
+	// corpus.
+	Missing int
+}
+
+// InShard reports whether the query belongs to the shard.
+func InShard(queryID string, shard, shards int) bool {
+	if shards <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(queryID))
+	return int(h.Sum32()%uint32(shards)) == shard
+}
+
+// Label labels the candidates of the queries in the shard, in the order of
+// queries, and appends them to the output file. The queries not in run are
+// skipped.
+func Label(ctx context.Context, cfg *Config, queries []eval.Query, corpus []retrieval.Document, run map[string][]retrieval.Hit) (Stats, error) {
+	var stats Stats
+	if cfg.Ranker == nil {
+		return stats, errors.New("no ranker")
+	}
+	if cfg.Path == "" {
+		return stats, errors.New("no output path")
+	}
+	shards := cfg.Shards
+	if shards <= 0 {
+		shards = 1
+	}
+	if cfg.Shard < 0 || cfg.Shard >= shards {
+		return stats, fmt.Errorf("shard %d is out of range [0, %d)", cfg.Shard, shards)
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+
+	out, progress, done, err := open(cfg.Path)
+	if err != nil {
+		return stats, err
+	}
+	defer out.Close()
+	defer progress.Close()
+
+	docs := make(map[string]retrieval.Document, len(corpus))
+	for _, d := range corpus {
+		docs[d.ID] = d
+	}
+
+	for _, q := range queries {
+		hits, ok := run[q.ID]
+		if !ok || !InShard(q.ID, cfg.Shard, shards) {
+			continue
+		}
+		if done[q.ID] {
+			stats.Resumed++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		if cfg.Depth > 0 && len(hits) > cfg.Depth {
+			hits = hits[:cfg.Depth]
+		}
+		var candidates []retrieval.Document
+		for _, h := range hits {
+			d, ok := docs[h.ID]
+			if !ok {
+				stats.Missing++
+				continue
+			}
+			candidates = append(candidates, d)
+		}
+
+		lines, err := label(ctx, cfg.Ranker, q, candidates, batchSize)
+		if err != nil {
+			return stats, fmt.Errorf("labeling query %s: %w", q.ID, err)
+		}
+		if err := commit(out, progress, q.ID, lines); err != nil {
+			return stats, err
+		}
+
+		stats.Queries++
+		stats.Pairs += len(candidates)
+	}
+	return stats, nil
+}
+
+// label scores the candidates of the query, and returns the output lines.
+func label(ctx context.Context, ranker retrieval.Ranker, q eval.Query, candidates []retrieval.Document, batchSize int) ([]byte, error) {
+	var buf bytes.Buffer
+	for start := 0; start < len(candidates); start += batchSize {
+		batch := candidates[start:min(start+batchSize, len(candidates))]
+
+		paras := make([]string, len(batch))
+		titles := make([]string, len(batch))
+		for i, c := range batch {
+			paras[i], titles[i] = c.Para, c.Title
+		}
+
+		scores, err := ranker.RankQueryContext(ctx, q.Text, paras, titles)
+		if err != nil {
+			return nil, err
+		}
+		if len(scores) != len(batch) {
+			return nil, fmt.Errorf("got %d scores, want %d", len(scores), len(batch))
+		}
+
+		for i, c := range batch {
+			score := strconv.FormatFloat(float64(scores[i]), 'f', 6, 32)
+			if err := eval.WriteFields(&buf, q.ID, c.ID, q.Text, c.Title, c.Para, score); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// The progress file has one line of "query_id\toffset" per labeled query,
+// where offset is the size of the output file after the lines of the query
+// were written.
+
+// open opens the output file and the progress file for appending, and
+// returns the queries labeled before. The lines written after the last
+// recorded offset (e.g. by an interrupted job) are discarded.
+func open(path string) (out, progress *os.File, done map[string]bool, err error) {
+	done = make(map[string]bool)
+	var offset int64
+
+	progress, err = os.OpenFile(path+".progress", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Only the complete lines count, since the last one may have been cut
+	// short by an interruption.
+	var valid int64
+	r := bufio.NewReader(progress)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			progress.Close()
+			return nil, nil, nil, err
+		}
+
+		queryID, off, ok := strings.Cut(strings.TrimSuffix(line, "\n"), "\t")
+		n, parseErr := strconv.ParseInt(off, 10, 64)
+		if !ok || parseErr != nil {
+			progress.Close()
+			return nil, nil, nil, fmt.Errorf("%s.progress: bad line %q", path, line)
+		}
+		done[queryID] = true
+		offset = n
+		valid += int64(len(line))
+	}
+	if err := truncate(progress, valid); err != nil {
+		progress.Close()
+		return nil, nil, nil, err
+	}
+
+	out, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		progress.Close()
+		return nil, nil, nil, err
+	}
+	info, err := out.Stat()
+	if err == nil && info.Size() < offset {
+		err = fmt.Errorf("%s is shorter than recorded in %s.progress", path, path)
+	}
+	if err == nil {
+		err = truncate(out, offset)
+	}
+	if err != nil {
+		out.Close()
+		progress.Close()
+		return nil, nil, nil, err
+	}
+	return out, progress, done, nil
+}
+
+// truncate truncates f to size, and moves the offset to the end.
+func truncate(f *os.File, size int64) error {
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	_, err := f.Seek(size, io.SeekStart)
+	return err
+}
+
+// commit writes the lines of the query to the output file, and then records
+// the progress. Both are synced, so that the progress never runs ahead of
+// the output.
+func commit(out, progress *os.File, queryID string, lines []byte) error {
+	if _, err := out.Write(lines); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+
+	offset, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(progress, "%s\t%d\n", queryID, offset); err != nil {
+		return err
+	}
+	return progress.Sync()
+}
@@ -0,0 +1,157 @@
+package distill_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-aie/rocketqa/distill"
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/retrieval/retrievaltest"
+	"github.com/google/go-cmp/cmp"
+)
+
+var (
+	corpus = []retrieval.Document{
+		{ID: "d1", Title: "t1", Para: "p1"},
+		{ID: "d2", Para: "p2"},
+	}
+	queries = []eval.Query{
+		{ID: "q1", Text: "query one"},
+		{ID: "q2", Text: "query two"},
+		{ID: "q3", Text: "query three"},
+		{ID: "q4", Text: "query four"}, // Not in the run.
+	}
+	run = map[string][]retrieval.Hit{
+		"q1": {{Document: retrieval.Document{ID: "d1"}}, {Document: retrieval.Document{ID: "d2"}}},
+		"q2": {{Document: retrieval.Document{ID: "d2"}}, {Document: retrieval.Document{ID: "d9"}}},
+		"q3": {{Document: retrieval.Document{ID: "d1"}}},
+	}
+	wantLines = []string{
+		"q1\td1\tquery one\tt1\tp1\t0.100000",
+		"q1\td2\tquery one\t\tp2\t0.200000",
+		"q2\td2\tquery two\t\tp2\t0.200000",
+		"q3\td1\tquery three\tt1\tp1\t0.100000",
+	}
+)
+
+func TestLabel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.tsv")
+	stats, err := distill.Label(context.Background(), &distill.Config{
+		Ranker:    retrievaltest.Ranker{"p1": 0.1, "p2": 0.2},
+		Path:      path,
+		BatchSize: 1,
+	}, queries, corpus, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStats := distill.Stats{Queries: 3, Pairs: 4, Missing: 1}
+	if !cmp.Equal(stats, wantStats) {
+		diff := cmp.Diff(wantStats, stats)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	if got := readLines(t, path); !cmp.Equal(got, wantLines) {
+		diff := cmp.Diff(wantLines, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestLabel_Shards(t *testing.T) {
+	dir := t.TempDir()
+
+	var got []string
+	for shard := 0; shard < 2; shard++ {
+		path := filepath.Join(dir, fmt.Sprintf("labels-%d.tsv", shard))
+		_, err := distill.Label(context.Background(), &distill.Config{
+			Ranker: retrievaltest.Ranker{"p1": 0.1, "p2": 0.2},
+			Path:   path,
+			Shard:  shard,
+			Shards: 2,
+		}, queries, corpus, run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, readLines(t, path)...)
+	}
+
+	// Every query is labeled by exactly one shard.
+	sort.Strings(got)
+	if !cmp.Equal(got, wantLines) {
+		diff := cmp.Diff(wantLines, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestLabel_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "labels.tsv")
+	cfg := &distill.Config{
+		Ranker: failingRanker{Ranker: retrievaltest.Ranker{"p1": 0.1, "p2": 0.2}, query: "query two"},
+		Path:   path,
+	}
+
+	_, err := distill.Label(context.Background(), cfg, queries, corpus, run)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+
+	// Simulate an interruption in the middle of writing.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("q2\td2\tquery t")
+	f.Close()
+
+	cfg.Ranker = retrievaltest.Ranker{"p1": 0.1, "p2": 0.2}
+	stats, err := distill.Label(context.Background(), cfg, queries, corpus, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// q1 was labeled by the first run, and the partial lines of q2 are
+	// discarded.
+	if stats.Resumed != 1 || stats.Queries != 2 {
+		t.Errorf("got stats %+v", stats)
+	}
+	if got := readLines(t, path); !cmp.Equal(got, wantLines) {
+		diff := cmp.Diff(wantLines, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// A finished job has nothing left to do.
+	stats, err = distill.Label(context.Background(), cfg, queries, corpus, run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Resumed != 3 || stats.Queries != 0 {
+		t.Errorf("got stats %+v", stats)
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// failingRanker fails on the given query.
+type failingRanker struct {
+	retrieval.Ranker
+	query string
+}
+
+func (r failingRanker) RankQueryContext(ctx context.Context, query string, paras, titles []string) ([]float32, error) {
+	if query == r.query {
+		return nil, errors.New("failed")
+	}
+	return r.Ranker.RankQueryContext(ctx, query, paras, titles)
+}
@@ -1,10 +1,12 @@
 package rocketqa
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/go-aie/paddle"
 	"github.com/go-aie/rocketqa/internal"
+	"github.com/go-aie/rocketqa/vecmath"
 )
 
 type DualEncoderConfig struct {
@@ -14,14 +16,57 @@ type DualEncoderConfig struct {
 	QueryMaxSeqLength     int
 	ParaMaxSeqLength      int
 	ForCN                 bool
+	// The title of the paras without titles, i.e. when the titles are
+	// omitted or empty. Defaults to none, e.g. set it to "-" for the
+	// placeholder used for the missing texts in the model inputs.
+	TitlePlaceholder string
 	// The maximum number of predictors for concurrent inferences.
 	// Defaults to the value of runtime.NumCPU.
 	MaxConcurrency int
+	// Whether EncodePara fails with ErrInputTooLong, instead of truncating
+	// the paras (along with their titles) longer than ParaMaxSeqLength.
+	// The queries are always truncated.
+	RejectTooLong bool
+	// Whether to scale the output vectors to unit length (L2 normalization),
+	// which is required by dot-product similarity search.
+	Normalize bool
+	// An optional transform applied to every output vector, such as a
+	// dimensionality reduction (see package pca). It is applied before
+	// the normalization, if any.
+	Transform Transformer
+	// Whether to run synthetic inputs at the maximum sequence lengths
+	// through every predictor in the pool before NewDualEncoder returns, to
+	// keep the first requests from being slow. See WarmUpReport for the
+	// results.
+	WarmUp bool
+	// Optional hooks for instrumentation, e.g. metrics and tracing.
+	Hooks Hooks
+}
+
+// Transformer transforms a vector into another one, possibly of a different
+// dimension.
+//
+// A transformer may also have a Fingerprint() string method identifying its
+// parameters (e.g. the components of a PCA), which is then recorded in
+// DualEncoder.Fingerprint. Otherwise, only its type is recorded.
+type Transformer interface {
+	Transform(v []float32) []float32
 }
 
 type DualEncoder struct {
-	engine    *paddle.Engine
+	engine    *engine
 	generator *internal.Generator
+	normalize bool
+	transform Transformer
+	hooks     Hooks
+
+	rejectTooLong bool
+
+	// fingerprint identifies the model and the settings that affect the
+	// output vectors.
+	fingerprint string
+
+	warmUpReport WarmUpReport
 }
 
 func NewDualEncoder(cfg *DualEncoderConfig) (*DualEncoder, error) {
@@ -31,59 +76,199 @@ func NewDualEncoder(cfg *DualEncoderConfig) (*DualEncoder, error) {
 		QueryMaxSeqLength: cfg.QueryMaxSeqLength,
 		ParaMaxSeqLength:  cfg.ParaMaxSeqLength,
 		ForCN:             cfg.ForCN,
+		TitlePlaceholder:  cfg.TitlePlaceholder,
 	})
 	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrVocab, err)
+	}
+	if err := checkModelFiles(cfg.ModelPath, cfg.ParamsPath); err != nil {
 		return nil, err
 	}
 
-	return &DualEncoder{
-		engine:    paddle.NewEngine(cfg.ModelPath, cfg.ParamsPath, cfg.MaxConcurrency),
-		generator: generator,
-	}, nil
+	fingerprint, err := modelFingerprint(
+		[]string{cfg.ModelPath, cfg.ParamsPath, cfg.VocabFile},
+		cfg.DoLowerCase, cfg.QueryMaxSeqLength, cfg.ParaMaxSeqLength, cfg.ForCN,
+		cfg.Normalize, transformFingerprint(cfg.Transform), cfg.TitlePlaceholder,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrModelLoad, err)
+	}
+	engine, err := newEngine(cfg.ModelPath, cfg.ParamsPath, cfg.MaxConcurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	de := &DualEncoder{
+		engine:      engine,
+		generator:   generator,
+		normalize:   cfg.Normalize,
+		transform:   cfg.Transform,
+		fingerprint: fingerprint,
+	}
+
+	if cfg.WarmUp {
+		query := warmUpQuery(cfg.QueryMaxSeqLength)
+		para := warmUpPara(cfg.ParaMaxSeqLength)
+		de.warmUpReport, err = warmUp(engine, func() error {
+			if _, err := de.EncodePara([]string{para}, []string{warmUpTitle}); err != nil {
+				return err
+			}
+			_, err := de.EncodeQueries(context.Background(), []string{query})
+			return err
+		})
+		if err != nil {
+			de.Close()
+			return nil, err
+		}
+	}
+	// Set after the warm-up, whose calls are not reported to the hooks.
+	de.hooks = cfg.Hooks
+	de.rejectTooLong = cfg.RejectTooLong
+
+	return de, nil
 }
 
+// Close releases the predictors, after waiting for the in-flight inferences
+// to finish. Later calls fail with ErrClosed (the deprecated EncodeQuery
+// panics with it).
+// It always returns nil.
+func (de *DualEncoder) Close() error {
+	de.engine.close()
+	return nil
+}
+
+// WarmUpReport returns the results of the warm-up, which is zero if
+// DualEncoderConfig.WarmUp is not set.
+func (de *DualEncoder) WarmUpReport() WarmUpReport {
+	return de.warmUpReport
+}
+
+// Fingerprint returns a string identifying the model and the settings that
+// affect the output vectors. It is derived from the contents of the model,
+// params and vocab files, so it stays the same if the files are moved, and
+// changes if they are replaced (e.g. by a fine-tuned model). Vectors from
+// encoders with different fingerprints are not comparable.
+//
+// For DualEncoderConfig.Transform, the fingerprint records its own
+// fingerprint if it has one (see Transformer), or only its type otherwise.
+func (de *DualEncoder) Fingerprint() string {
+	return de.fingerprint
+}
+
+// EncodeQuery is like EncodeQueries, but panics with the error, if any.
+//
+// Deprecated: Use EncodeQueries, which returns the errors instead.
 func (de *DualEncoder) EncodeQuery(queries []string) []Vector {
+	return mustVectors(de.EncodeQueries(context.Background(), queries))
+}
+
+// EncodeQueryContext is like EncodeQueries, but panics with the error, if
+// any.
+//
+// Deprecated: Use EncodeQueries, which returns the errors instead.
+func (de *DualEncoder) EncodeQueryContext(ctx context.Context, queries []string) []Vector {
+	return mustVectors(de.EncodeQueries(ctx, queries))
+}
+
+// EncodeQueries encodes the queries, passing ctx to the hooks (see
+// WithRequestID). It fails with an error matching ErrInference if the
+// inference fails, or ErrClosed if the encoder is closed.
+func (de *DualEncoder) EncodeQueries(ctx context.Context, queries []string) (vectors []Vector, err error) {
 	if len(queries) == 0 {
-		return nil
+		return nil, nil
 	}
 
+	c := startCall(ctx, de.hooks, OpEncodeQuery, len(queries))
+	defer c.end(&err)
+
 	var dataSet []internal.Data
 	for _, query := range queries {
 		dataSet = append(dataSet, de.generator.GenerateDE(internal.NewExampleFromQuery(query)))
 	}
 
-	inputs := de.getInputs(dataSet)
-	outputs := de.engine.Infer(inputs)
-
+	outputs, err := de.infer(c, dataSet)
+	if err != nil {
+		return nil, err
+	}
 	result := outputs[0] // 0: q_rep, 1: p_rep
-	m := paddle.NewMatrix[float32](result)
-	return newVectors(m.Rows())
+	return de.newVectors(result), nil
+}
+
+// mustVectors returns vectors, or panics with err if it is not nil. It backs
+// the deprecated methods that cannot return errors.
+func mustVectors(vectors []Vector, err error) []Vector {
+	if err != nil {
+		panic(err)
+	}
+	return vectors
 }
 
+// EncodePara encodes the paras along with their titles, which are optional:
+// titles is either empty or of the same length as paras.
 func (de *DualEncoder) EncodePara(paras, titles []string) ([]Vector, error) {
+	return de.EncodeParaContext(context.Background(), paras, titles)
+}
+
+// EncodeParaContext is like EncodePara, but passes ctx to the hooks (see
+// WithRequestID).
+func (de *DualEncoder) EncodeParaContext(ctx context.Context, paras, titles []string) (vectors []Vector, err error) {
 	n := len(paras)
 	if n == 0 {
 		return nil, nil
 	}
-	if len(titles) != n {
-		return nil, fmt.Errorf("len(titles) does not equal len(paras)")
+
+	c := startCall(ctx, de.hooks, OpEncodePara, n)
+	defer c.end(&err)
+
+	if err := checkTitles(paras, titles); err != nil {
+		return nil, err
 	}
 
 	var dataSet []internal.Data
 	for i := 0; i < n; i++ {
-		title := ""
-		if len(titles) > 0 {
-			title = titles[i]
+		d := de.generator.GenerateDE(internal.NewExampleFromPara(paras[i], titleAt(titles, i)))
+		if de.rejectTooLong && d.Para.Truncated > 0 {
+			return nil, &InputError{Arg: "paras", Index: i, Err: ErrInputTooLong}
 		}
-		dataSet = append(dataSet, de.generator.GenerateDE(internal.NewExampleFromPara(paras[i], title)))
+		dataSet = append(dataSet, d)
+	}
+
+	outputs, err := de.infer(c, dataSet)
+	if err != nil {
+		return nil, err
 	}
+	result := outputs[1] // 0: q_rep, 1: p_rep
+	return de.newVectors(result), nil
+}
+
+// infer pads the data and runs the model, recording the stats in c.
+func (de *DualEncoder) infer(c *call, dataSet []internal.Data) ([]paddle.Tensor, error) {
+	c.mark(phaseTokenize)
 
 	inputs := de.getInputs(dataSet)
-	outputs := de.engine.Infer(inputs)
+	if c != nil {
+		var tokens, truncated int
+		for _, d := range dataSet {
+			tokens += len(d.Query.TokenIDs) + len(d.Para.TokenIDs)
+			if d.Query.Truncated > 0 || d.Para.Truncated > 0 {
+				truncated++
+			}
+		}
+		// 0: query token IDs, 4: para token IDs
+		c.addTokens(tokens, numElements(inputs[0])+numElements(inputs[4]), truncated)
+	}
+	c.mark(phasePad)
 
-	result := outputs[1] // 0: q_rep, 1: p_rep
-	m := paddle.NewMatrix[float32](result)
-	return newVectors(m.Rows()), nil
+	outputs, queueWait, inference, err := de.engine.infer(inputs)
+	c.addInference(queueWait, inference)
+	if err != nil {
+		return nil, err
+	}
+	// 0: q_rep, 1: p_rep
+	if err := checkOutputs(outputs, 2, len(dataSet)); err != nil {
+		return nil, err
+	}
+	return outputs, nil
 }
 
 func (de *DualEncoder) getInputs(dataSet []internal.Data) []paddle.Tensor {
@@ -122,21 +307,30 @@ func (de *DualEncoder) getInputs(dataSet []internal.Data) []paddle.Tensor {
 	}
 }
 
-type Vector []float32
-
-func (v Vector) Norm() Vector {
-	m := paddle.NewMatrix[float32](paddle.NewInputTensorFromTwoDimSlice([][]float32{v}))
-	return m.Norm().RawData()
-}
+// Vector is an embedding produced by DualEncoder.
+type Vector = vecmath.Vector
 
-func (v Vector) ToFloat64() []float64 {
-	return paddle.NumberToFloat64(v)
-}
+// newVectors splits the 2-D output tensor into row vectors in one pass,
+// applying the output transform and the normalization if configured.
+func (de *DualEncoder) newVectors(t paddle.Tensor) []Vector {
+	data, ok := t.Data.([]float32)
+	if !ok {
+		data = paddle.NewTypedTensor[float32](t).Data
+	}
 
-func newVectors(value [][]float32) []Vector {
-	var vectors []Vector
-	for _, v := range value {
-		vectors = append(vectors, v)
+	rows, cols := int(t.Shape[0]), int(t.Shape[1])
+	vectors := make([]Vector, rows)
+	for i := range vectors {
+		// Limit the capacity so that appending to one vector never
+		// overwrites the next one, which shares the same backing array.
+		v := Vector(data[i*cols : (i+1)*cols : (i+1)*cols])
+		if de.transform != nil {
+			v = de.transform.Transform(v)
+		}
+		if de.normalize {
+			vecmath.NormalizeInPlace(v)
+		}
+		vectors[i] = v
 	}
 	return vectors
 }
@@ -1,13 +1,16 @@
 package rocketqa_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
 
 	"github.com/go-aie/rocketqa"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestDualEncoder_EncodeQuery(t *testing.T) {
@@ -87,6 +90,99 @@ func TestDualEncoder_EncodePara(t *testing.T) {
 	}
 }
 
+func TestDualEncoder_Normalize(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newDualEncoderConfig(1)
+	cfg.Normalize = true
+	normDE, err := rocketqa.NewDualEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inQPTs := rocketqa.QPTs{
+		{
+			Query: "你好，世界！",
+			Para:  "这是一段较长的文本。",
+		},
+		{
+			Query: "Hello, World!",
+			Para:  "This is a long paragraph.",
+		},
+	}
+
+	opt := cmpopts.EquateApprox(0, 1e-6)
+
+	wantQueryVectors := de.EncodeQuery(inQPTs.Q())
+	gotQueryVectors := normDE.EncodeQuery(inQPTs.Q())
+	for i, got := range gotQueryVectors {
+		want := wantQueryVectors[i].Norm()
+		if !cmp.Equal(got, want, opt) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+
+	wantParaVectors, _ := de.EncodePara(inQPTs.P(), inQPTs.T())
+	gotParaVectors, _ := normDE.EncodePara(inQPTs.P(), inQPTs.T())
+	for i, got := range gotParaVectors {
+		want := wantParaVectors[i].Norm()
+		if !cmp.Equal(got, want, opt) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+// truncate is a Transformer that keeps only the first n values.
+type truncate int
+
+func (n truncate) Transform(v []float32) []float32 {
+	return v[:n]
+}
+
+func TestDualEncoder_Transform(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newDualEncoderConfig(1)
+	cfg.Transform = truncate(2)
+	cfg.Normalize = true
+	tDE, err := rocketqa.NewDualEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inQPTs := rocketqa.QPTs{
+		{
+			Query: "你好，世界！",
+			Para:  "这是一段较长的文本。",
+		},
+	}
+
+	opt := cmpopts.EquateApprox(0, 1e-6)
+
+	want := de.EncodeQuery(inQPTs.Q())[0][:2].Norm()
+	got := tDE.EncodeQuery(inQPTs.Q())[0]
+	if !cmp.Equal(got, want, opt) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	wantParaVectors, _ := de.EncodePara(inQPTs.P(), inQPTs.T())
+	gotParaVectors, _ := tDE.EncodePara(inQPTs.P(), inQPTs.T())
+	want, got = wantParaVectors[0][:2].Norm(), gotParaVectors[0]
+	if !cmp.Equal(got, want, opt) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
 func BenchmarkDualEncoder_EncodeQuery(b *testing.B) {
 	inQPTs := rocketqa.QPTs{
 		{
@@ -163,8 +259,82 @@ func BenchmarkDualEncoder_EncodePara(b *testing.B) {
 	}
 }
 
+func TestDualEncoder_Fingerprint(t *testing.T) {
+	newFingerprint := func(cfg *rocketqa.DualEncoderConfig) string {
+		de, err := rocketqa.NewDualEncoder(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return de.Fingerprint()
+	}
+
+	want := newFingerprint(newDualEncoderConfig(1))
+
+	// The fingerprint does not depend on the concurrency.
+	if got := newFingerprint(newDualEncoderConfig(2)); got != want {
+		t.Errorf("Fingerprint: Got (%v) != Want (%v)", got, want)
+	}
+
+	// But it depends on the settings that affect the vectors.
+	cfg := newDualEncoderConfig(1)
+	cfg.ParaMaxSeqLength = 128
+	if got := newFingerprint(cfg); got == want {
+		t.Errorf("Want a different fingerprint for ParaMaxSeqLength")
+	}
+
+	// And on the parameters of the transform, if it has a fingerprint.
+	cfg = newDualEncoderConfig(1)
+	cfg.Transform = fingerprintedTransform("a")
+	wantTransform := newFingerprint(cfg)
+	cfg.Transform = fingerprintedTransform("b")
+	if got := newFingerprint(cfg); got == wantTransform || got == want {
+		t.Errorf("Want a different fingerprint for Transform")
+	}
+
+	cfg = newDualEncoderConfig(1)
+	cfg.ParamsPath = "./testdata/not_exist.pdiparams"
+	if _, err := rocketqa.NewDualEncoder(cfg); err == nil {
+		t.Errorf("Want error for missing params file")
+	}
+}
+
+func TestDualEncoder_WarmUp(t *testing.T) {
+	cfg := newDualEncoderConfig(2)
+	cfg.WarmUp = true
+	de, err := rocketqa.NewDualEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := de.WarmUpReport()
+	if report.Runs != 2 {
+		t.Errorf("Runs: Got (%v) != Want (2)", report.Runs)
+	}
+	if report.Predictors < 1 || report.Predictors > report.Runs {
+		t.Errorf("Predictors: Got (%v), want in [1, %v]", report.Predictors, report.Runs)
+	}
+	if report.Duration <= 0 || report.MaxLatency <= 0 || report.MaxLatency > report.Duration {
+		t.Errorf("Got invalid latencies: %+v", report)
+	}
+}
+
+// fingerprintedTransform is an identity transform with a fingerprint.
+type fingerprintedTransform string
+
+func (t fingerprintedTransform) Transform(v []float32) []float32 {
+	return v
+}
+
+func (t fingerprintedTransform) Fingerprint() string {
+	return string(t)
+}
+
 func newDualEncoder(maxConcurrency int) (*rocketqa.DualEncoder, error) {
-	return rocketqa.NewDualEncoder(&rocketqa.DualEncoderConfig{
+	return rocketqa.NewDualEncoder(newDualEncoderConfig(maxConcurrency))
+}
+
+func newDualEncoderConfig(maxConcurrency int) *rocketqa.DualEncoderConfig {
+	return &rocketqa.DualEncoderConfig{
 		ModelPath:         "./testdata/zh_dureader_de_v2.pdmodel",
 		ParamsPath:        "./testdata/zh_dureader_de_v2.pdiparams",
 		VocabFile:         "./testdata/zh_vocab.txt",
@@ -173,7 +343,7 @@ func newDualEncoder(maxConcurrency int) (*rocketqa.DualEncoder, error) {
 		ParaMaxSeqLength:  384,
 		ForCN:             true,
 		MaxConcurrency:    maxConcurrency,
-	})
+	}
 }
 
 func getEmbedding(t *testing.T, typ string, text string) []string {
@@ -189,3 +359,37 @@ func getEmbedding(t *testing.T, typ string, text string) []string {
 
 	return m[typ][text]
 }
+
+func TestDualEncoder_Close(t *testing.T) {
+	de, err := newDualEncoder(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := de.EncodePara([]string{"这是一段较长的文本。"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := de.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing twice is harmless.
+	if err := de.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := de.EncodePara([]string{"这是一段较长的文本。"}, nil); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("EncodePara: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
+	if _, err := de.EncodeQueries(context.Background(), []string{"你好"}); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("EncodeQueries: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, rocketqa.ErrClosed) {
+				t.Errorf("EncodeQuery: got panic %v, want %v", err, rocketqa.ErrClosed)
+			}
+		}()
+		de.EncodeQuery([]string{"你好"})
+	}()
+}
@@ -0,0 +1,172 @@
+package rocketqa
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-aie/paddle"
+)
+
+// engine wraps paddle.Engine, and limits the concurrent inferences to the
+// size of its predictor pool, so that the time waiting for an idle predictor
+// can be measured.
+type engine struct {
+	*paddle.Engine // Nil after close.
+	sem            chan struct{}
+	closeMu        sync.Mutex
+
+	// The number of the running inferences, and its maximum since the
+	// last resetPeak. Each running inference holds a distinct predictor.
+	active, maxActive atomic.Int32
+}
+
+// newEngine creates the engine, which loads the model into all the
+// predictors of the pool before returning. The model files must have been
+// checked by checkModelFiles, since paddle.NewEngine does not report such
+// failures.
+func newEngine(model, params string, maxConcurrency int) (e *engine, err error) {
+	defer func() {
+		// paddle.NewEngine panics if it fails to create the predictors.
+		if r := recover(); r != nil {
+			e, err = nil, fmt.Errorf("%w: %v", ErrModelLoad, r)
+		}
+	}()
+	return &engine{
+		Engine: paddle.NewEngine(model, params, maxConcurrency),
+		sem:    make(chan struct{}, poolSize(maxConcurrency)),
+	}, nil
+}
+
+// checkModelFiles checks that the model and params files are non-empty
+// regular files, which can be read.
+func checkModelFiles(model, params string) error {
+	for _, f := range []struct{ kind, name string }{{"model", model}, {"params", params}} {
+		if f.name == "" {
+			return fmt.Errorf("%w: no %s file", ErrModelLoad, f.kind)
+		}
+		if err := checkReadable(f.name); err != nil {
+			return fmt.Errorf("%w: %s file: %w", ErrModelLoad, f.kind, err)
+		}
+	}
+	return nil
+}
+
+func checkReadable(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	switch {
+	case err != nil:
+		return err
+	case info.IsDir():
+		return fmt.Errorf("%s is a directory", name)
+	case info.Size() == 0:
+		return fmt.Errorf("%s is empty", name)
+	}
+	// Opening a file does not always detect that it is unreadable, e.g. on
+	// network file systems.
+	_, err = file.Read(make([]byte, 1))
+	return err
+}
+
+// close waits for the in-flight inferences to finish, and then drops the
+// predictors. paddle.Engine has no way to destroy them explicitly, thus they
+// are destroyed by their finalizers once garbage collected. Later inferences
+// fail with ErrClosed.
+func (e *engine) close() {
+	e.closeMu.Lock()
+	defer e.closeMu.Unlock()
+
+	// Holding all the slots keeps any inference from running.
+	for i := 0; i < cap(e.sem); i++ {
+		e.sem <- struct{}{}
+	}
+	e.Engine = nil
+	for i := 0; i < cap(e.sem); i++ {
+		<-e.sem
+	}
+}
+
+// infer runs the inference, and returns the outputs along with the time
+// waiting for a predictor and the time running the inference.
+func (e *engine) infer(inputs []paddle.Tensor) (outputs []paddle.Tensor, queueWait, inference time.Duration, err error) {
+	start := time.Now()
+	e.sem <- struct{}{}
+	defer func() { <-e.sem }()
+
+	if e.Engine == nil {
+		return nil, time.Since(start), 0, ErrClosed
+	}
+
+	n := e.active.Add(1)
+	defer e.active.Add(-1)
+	for {
+		m := e.maxActive.Load()
+		if n <= m || e.maxActive.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	running := time.Now()
+	defer func() {
+		// paddle.Engine panics on failures, such as inputs that do not
+		// match the model.
+		if r := recover(); r != nil {
+			outputs, err = nil, fmt.Errorf("%w: %v", ErrInference, r)
+		}
+		queueWait, inference = running.Sub(start), time.Since(running)
+	}()
+	outputs = e.Infer(inputs)
+	return outputs, 0, 0, nil
+}
+
+// resetPeak resets the maximum number of the running inferences.
+func (e *engine) resetPeak() {
+	e.maxActive.Store(0)
+}
+
+// peak returns the maximum number of the running inferences since the last
+// resetPeak.
+func (e *engine) peak() int {
+	return int(e.maxActive.Load())
+}
+
+// checkOutputs checks that there are at least n outputs, each of which is a
+// matrix with the given number of rows.
+func checkOutputs(outputs []paddle.Tensor, n, rows int) error {
+	if len(outputs) < n {
+		return fmt.Errorf("%w: got %d outputs, want %d", ErrInference, len(outputs), n)
+	}
+	for _, t := range outputs[:n] {
+		if len(t.Shape) != 2 || int(t.Shape[0]) != rows {
+			return fmt.Errorf("%w: got output shape %v, want %d rows", ErrInference, t.Shape, rows)
+		}
+	}
+	return nil
+}
+
+// poolSize returns the size of the predictor pool for maxConcurrency, which
+// follows paddle.NewPredictorPool.
+func poolSize(maxConcurrency int) int {
+	if maxConcurrency < 1 {
+		return runtime.NumCPU()
+	}
+	return maxConcurrency
+}
+
+// numElements returns the number of elements in the tensor.
+func numElements(t paddle.Tensor) int {
+	n := 1
+	for _, d := range t.Shape {
+		n *= int(d)
+	}
+	return n
+}
@@ -0,0 +1,47 @@
+package rocketqa
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The errors of the encoders, which can be tested with errors.Is. Some of them
+// are wrapped in error types with more details, such as LengthError and
+// InputError, which can be retrieved with errors.As.
+var (
+	// ErrLengthMismatch means that the arguments of an encoder method have
+	// mismatched lengths. See LengthError.
+	ErrLengthMismatch = errors.New("length mismatch")
+	// ErrVocab means that the vocab file is missing or invalid.
+	ErrVocab = errors.New("failed to load vocab")
+	// ErrModelLoad means that the model files are missing or invalid.
+	ErrModelLoad = errors.New("failed to load model")
+	// ErrInference means that the inference engine failed, or returned
+	// unexpected outputs.
+	ErrInference = errors.New("inference failed")
+	// ErrInputTooLong means that an input exceeds the maximum sequence
+	// length, and truncation is disabled (see
+	// DualEncoderConfig.RejectTooLong). See InputError.
+	ErrInputTooLong = errors.New("input too long")
+	// ErrClosed means that the encoder has been closed.
+	ErrClosed = errors.New("encoder closed")
+)
+
+// InputError reports an error about a single input of an encoder method.
+type InputError struct {
+	// The name of the argument, such as "paras", and the index of the
+	// input in it. For the cross encoder, whose (query, para, title) triples
+	// only overflow as a whole, Arg is "inputs" and Index is that of the
+	// triple, or of the para for the methods with a single query.
+	Arg   string
+	Index int
+	Err   error
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("%s[%d]: %v", e.Arg, e.Index, e.Err)
+}
+
+func (e *InputError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,129 @@
+package rocketqa_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+)
+
+func TestNewDualEncoder_Error(t *testing.T) {
+	dir := t.TempDir()
+	badVocab := filepath.Join(dir, "vocab.txt")
+	if err := os.WriteFile(badVocab, []byte("[PAD]\n[CLS]\n[SEP]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emptyModel := filepath.Join(dir, "empty.pdmodel")
+	if err := os.WriteFile(emptyModel, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		inCfg   func(cfg *rocketqa.DualEncoderConfig)
+		wantErr error
+	}{
+		{
+			name:    "no vocab file",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.VocabFile = "./testdata/missing.txt" },
+			wantErr: rocketqa.ErrVocab,
+		},
+		{
+			name:    "no unknown token",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.VocabFile = badVocab },
+			wantErr: rocketqa.ErrVocab,
+		},
+		{
+			name:    "no model path",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.ModelPath = "" },
+			wantErr: rocketqa.ErrModelLoad,
+		},
+		{
+			name:    "empty model file",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.ModelPath = emptyModel },
+			wantErr: rocketqa.ErrModelLoad,
+		},
+		{
+			name:    "params directory",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.ParamsPath = dir },
+			wantErr: rocketqa.ErrModelLoad,
+		},
+		{
+			name:    "no params file",
+			inCfg:   func(cfg *rocketqa.DualEncoderConfig) { cfg.ParamsPath = "./testdata/missing.pdiparams" },
+			wantErr: rocketqa.ErrModelLoad,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newDualEncoderConfig(1)
+			tt.inCfg(cfg)
+			_, err := rocketqa.NewDualEncoder(cfg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncoders_RejectTooLong(t *testing.T) {
+	paras := []string{"这是一段较长的文本。", strings.Repeat("长", 400)}
+	wantDEErr := &rocketqa.InputError{Arg: "paras", Index: 1, Err: rocketqa.ErrInputTooLong}
+	wantCEErr := &rocketqa.InputError{Arg: "inputs", Index: 1, Err: rocketqa.ErrInputTooLong}
+
+	deCfg := newDualEncoderConfig(1)
+	deCfg.RejectTooLong = true
+	de, err := rocketqa.NewDualEncoder(deCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := de.EncodePara(paras[:1], nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = de.EncodePara(paras, nil)
+	checkInputError(t, err, wantDEErr)
+
+	ceCfg := newCrossEncoderConfig(1)
+	ceCfg.RejectTooLong = true
+	ce, err := rocketqa.NewCrossEncoder(ceCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ce.RankQuery("你好", paras[:1], nil); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ce.RankQuery("你好", paras, nil)
+	checkInputError(t, err, wantCEErr)
+	_, err = ce.Rank([]string{"你好", "你好"}, paras, nil)
+	checkInputError(t, err, wantCEErr)
+
+	// A long query overflows along with a short para.
+	_, err = ce.Rank([]string{"你好", strings.Repeat("长", 400)}, []string{"段落", "段落"}, nil)
+	checkInputError(t, err, wantCEErr)
+}
+
+func TestLengthError(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ce.Rank([]string{"你好"}, nil, nil)
+	if !errors.Is(err, rocketqa.ErrLengthMismatch) {
+		t.Errorf("got error %v, want %v", err, rocketqa.ErrLengthMismatch)
+	}
+}
+
+func checkInputError(t *testing.T, err error, want *rocketqa.InputError) {
+	t.Helper()
+	if !errors.Is(err, want.Err) {
+		t.Errorf("got error %v, want %v", err, want.Err)
+	}
+	var got *rocketqa.InputError
+	if !errors.As(err, &got) || *got != *want {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
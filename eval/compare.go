@@ -0,0 +1,244 @@
+package eval
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type CompareConfig struct {
+	// The number of bootstrap samples and random permutations of the
+	// significance tests. Defaults to 10000.
+	Samples int
+	// The seed of the random number generator, which makes the p-values
+	// reproducible. Defaults to 1.
+	Seed int64
+	// The number of the most regressed queries to report per metric.
+	// Defaults to 10.
+	Worst int
+}
+
+// QueryDiff is the change of a metric on one query.
+type QueryDiff struct {
+	QueryID   string
+	Baseline  float64
+	Candidate float64
+}
+
+// Diff returns the value of the candidate minus that of the baseline.
+func (d QueryDiff) Diff() float64 {
+	return d.Candidate - d.Baseline
+}
+
+// Comparison is the comparison between two runs on one metric.
+type Comparison struct {
+	Metric string
+	// The means of the baseline and the candidate over the paired queries.
+	Baseline, Candidate float64
+	// The numbers of the queries on which the candidate is better, worse and
+	// equal.
+	Wins, Losses, Ties int
+	// The two-sided p-values of the paired bootstrap test and the paired
+	// randomization (permutation) test, under the null hypothesis that the
+	// two runs have the same mean.
+	BootstrapP, RandomizationP float64
+	// The queries on which the candidate lost the most, worst first.
+	Regressions []QueryDiff
+}
+
+// Diff returns the mean of the candidate minus that of the baseline.
+func (c Comparison) Diff() float64 {
+	return c.Candidate - c.Baseline
+}
+
+// Compare compares the per-query metrics of a candidate run against those of
+// a baseline run (see ReadPerQuery), on every metric present in both. Only
+// the queries present in both runs are compared.
+func Compare(baseline, candidate map[string]Metrics, cfg *CompareConfig) ([]Comparison, error) {
+	samples := cfg.Samples
+	if samples <= 0 {
+		samples = 10000
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = 1
+	}
+	worst := cfg.Worst
+	if worst <= 0 {
+		worst = 10
+	}
+
+	var queryIDs []string
+	for id := range baseline {
+		if _, ok := candidate[id]; ok {
+			queryIDs = append(queryIDs, id)
+		}
+	}
+	if len(queryIDs) == 0 {
+		return nil, errors.New("the runs have no queries in common")
+	}
+	sort.Strings(queryIDs)
+
+	var comparisons []Comparison
+	for _, name := range commonMetrics(baseline, candidate, queryIDs) {
+		diffs := make([]QueryDiff, len(queryIDs))
+		for i, id := range queryIDs {
+			diffs[i] = QueryDiff{
+				QueryID:   id,
+				Baseline:  baseline[id][name],
+				Candidate: candidate[id][name],
+			}
+		}
+
+		// Use the same random numbers for every metric.
+		rng := rand.New(rand.NewSource(seed))
+		comparisons = append(comparisons, compare(name, diffs, samples, worst, rng))
+	}
+	return comparisons, nil
+}
+
+func compare(name string, diffs []QueryDiff, samples, worst int, rng *rand.Rand) Comparison {
+	const eps = 1e-9
+
+	c := Comparison{Metric: name}
+	d := make([]float64, len(diffs))
+	for i, qd := range diffs {
+		c.Baseline += qd.Baseline / float64(len(diffs))
+		c.Candidate += qd.Candidate / float64(len(diffs))
+
+		d[i] = qd.Diff()
+		switch {
+		case d[i] > eps:
+			c.Wins++
+		case d[i] < -eps:
+			c.Losses++
+		default:
+			c.Ties++
+		}
+	}
+
+	c.BootstrapP = bootstrapTest(d, samples, rng)
+	c.RandomizationP = randomizationTest(d, samples, rng)
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return diffs[i].Diff() < diffs[j].Diff()
+	})
+	for _, qd := range diffs {
+		if len(c.Regressions) == worst || qd.Diff() >= -eps {
+			break
+		}
+		c.Regressions = append(c.Regressions, qd)
+	}
+	return c
+}
+
+// bootstrapTest returns the two-sided p-value of the paired bootstrap test
+// on the differences d. The samples are shifted to have a zero mean, which
+// is the null hypothesis, and the p-value is the fraction of them whose
+// means are at least as extreme as the observed one.
+func bootstrapTest(d []float64, samples int, rng *rand.Rand) float64 {
+	observed := mean(d)
+	if allZero(d) {
+		return 1
+	}
+
+	var extreme int
+	for s := 0; s < samples; s++ {
+		var sum float64
+		for range d {
+			sum += d[rng.Intn(len(d))]
+		}
+		if math.Abs(sum/float64(len(d))-observed) >= math.Abs(observed)-1e-12 {
+			extreme++
+		}
+	}
+	return float64(extreme+1) / float64(samples+1)
+}
+
+// randomizationTest returns the two-sided p-value of the paired
+// randomization test on the differences d, which swaps the runs on every
+// query at random (i.e. flips the sign of the difference).
+func randomizationTest(d []float64, samples int, rng *rand.Rand) float64 {
+	observed := mean(d)
+	if allZero(d) {
+		return 1
+	}
+
+	var extreme int
+	for s := 0; s < samples; s++ {
+		var sum float64
+		for _, x := range d {
+			if rng.Intn(2) == 0 {
+				x = -x
+			}
+			sum += x
+		}
+		if math.Abs(sum/float64(len(d))) >= math.Abs(observed)-1e-12 {
+			extreme++
+		}
+	}
+	return float64(extreme+1) / float64(samples+1)
+}
+
+func mean(a []float64) float64 {
+	var sum float64
+	for _, x := range a {
+		sum += x
+	}
+	return sum / float64(len(a))
+}
+
+func allZero(a []float64) bool {
+	for _, x := range a {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// commonMetrics returns the names of the metrics present in both runs for
+// all the queries, in the order of SortMetricNames.
+func commonMetrics(baseline, candidate map[string]Metrics, queryIDs []string) []string {
+	var names []string
+	for name := range baseline[queryIDs[0]] {
+		common := true
+		for _, id := range queryIDs {
+			_, inBaseline := baseline[id][name]
+			_, inCandidate := candidate[id][name]
+			if !inBaseline || !inCandidate {
+				common = false
+				break
+			}
+		}
+		if common {
+			names = append(names, name)
+		}
+	}
+	SortMetricNames(names)
+	return names
+}
+
+// SortMetricNames sorts the metric names by their base names, and then by
+// their cutoffs numerically (e.g. "recall@5" before "recall@10").
+func SortMetricNames(names []string) {
+	split := func(name string) (string, int) {
+		base, cutoff, ok := strings.Cut(name, "@")
+		if !ok {
+			return name, 0
+		}
+		k, _ := strconv.Atoi(cutoff)
+		return base, k
+	}
+	sort.Slice(names, func(i, j int) bool {
+		bi, ki := split(names[i])
+		bj, kj := split(names[j])
+		if bi != bj {
+			return bi < bj
+		}
+		return ki < kj
+	})
+}
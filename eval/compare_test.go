@@ -0,0 +1,81 @@
+package eval_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompare(t *testing.T) {
+	// The candidate is better on 40 queries, and worse on 2.
+	baseline := make(map[string]eval.Metrics)
+	candidate := make(map[string]eval.Metrics)
+	for i := 0; i < 42; i++ {
+		id := fmt.Sprintf("q%02d", i)
+		baseline[id] = eval.Metrics{"mrr@10": 0.5, "map": 0.3}
+		candidate[id] = eval.Metrics{"mrr@10": 1, "map": 0.3}
+	}
+	candidate["q40"] = eval.Metrics{"mrr@10": 0.25, "map": 0.3}
+	candidate["q41"] = eval.Metrics{"mrr@10": 0, "map": 0.3}
+	// Unpaired queries are ignored.
+	baseline["only-in-baseline"] = eval.Metrics{"mrr@10": 1, "map": 1}
+
+	comparisons, err := eval.Compare(baseline, candidate, &eval.CompareConfig{Samples: 1000, Worst: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparisons) != 2 {
+		t.Fatalf("got %d comparisons, want 2", len(comparisons))
+	}
+
+	// No difference.
+	m := comparisons[0]
+	if m.Metric != "map" || m.Ties != 42 || m.BootstrapP != 1 || m.RandomizationP != 1 || len(m.Regressions) != 0 {
+		t.Errorf("map: got %+v", m)
+	}
+
+	// A significant improvement.
+	mrr := comparisons[1]
+	if mrr.Metric != "mrr@10" || mrr.Wins != 40 || mrr.Losses != 2 || mrr.Ties != 0 {
+		t.Errorf("mrr@10: got %+v", mrr)
+	}
+	if mrr.BootstrapP > 0.01 || mrr.RandomizationP > 0.01 {
+		t.Errorf("mrr@10: got p-values %v and %v, want <= 0.01", mrr.BootstrapP, mrr.RandomizationP)
+	}
+	wantRegressions := []eval.QueryDiff{{QueryID: "q41", Baseline: 0.5, Candidate: 0}}
+	if !cmp.Equal(mrr.Regressions, wantRegressions) {
+		diff := cmp.Diff(wantRegressions, mrr.Regressions)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestCompare_NotSignificant(t *testing.T) {
+	// The candidate wins and loses by the same amounts.
+	baseline := make(map[string]eval.Metrics)
+	candidate := make(map[string]eval.Metrics)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("q%02d", i)
+		baseline[id] = eval.Metrics{"ndcg@10": 0.5}
+		candidate[id] = eval.Metrics{"ndcg@10": 0.5 + 0.1*float64(1-2*(i%2))}
+	}
+
+	comparisons, err := eval.Compare(baseline, candidate, &eval.CompareConfig{Samples: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := comparisons[0]; c.BootstrapP < 0.5 || c.RandomizationP < 0.5 {
+		t.Errorf("got p-values %v and %v, want >= 0.5", c.BootstrapP, c.RandomizationP)
+	}
+}
+
+func TestSortMetricNames(t *testing.T) {
+	got := []string{"recall@10", "ndcg@10", "recall@5", "map", "recall@100", "mrr@10"}
+	eval.SortMetricNames(got)
+	want := []string{"map", "mrr@10", "ndcg@10", "recall@5", "recall@10", "recall@100"}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
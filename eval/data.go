@@ -0,0 +1,141 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+// Query is a query to be evaluated.
+type Query struct {
+	ID   string
+	Text string
+}
+
+// Qrels holds the relevance judgments, which map query IDs to document IDs
+// to relevance grades. Documents with positive grades are relevant, and the
+// unjudged documents are irrelevant.
+type Qrels map[string]map[string]int
+
+// Relevant returns the number of relevant documents of the query.
+func (q Qrels) Relevant(queryID string) int {
+	return countRelevant(q[queryID])
+}
+
+// ReadCorpus reads the documents in TSV format, one per line, as either
+// "id\ttitle\tpara" or "id\tpara".
+func ReadCorpus(r io.Reader) ([]retrieval.Document, error) {
+	var docs []retrieval.Document
+	err := readLines(r, func(lineNum int, line string) error {
+		parts := strings.Split(line, "\t")
+		switch len(parts) {
+		case 2:
+			docs = append(docs, retrieval.Document{ID: parts[0], Para: parts[1]})
+		case 3:
+			docs = append(docs, retrieval.Document{ID: parts[0], Title: parts[1], Para: parts[2]})
+		default:
+			return fmt.Errorf("line %d: got %d fields, want 2 or 3", lineNum, len(parts))
+		}
+		return nil
+	})
+	return docs, err
+}
+
+// ReadQueries reads the queries in TSV format, one per line, as
+// "id\tquery".
+func ReadQueries(r io.Reader) ([]Query, error) {
+	var queries []Query
+	err := readLines(r, func(lineNum int, line string) error {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 2 {
+			return fmt.Errorf("line %d: got %d fields, want 2", lineNum, len(parts))
+		}
+		queries = append(queries, Query{ID: parts[0], Text: parts[1]})
+		return nil
+	})
+	return queries, err
+}
+
+// ReadQrels reads the relevance judgments in either of the following
+// formats, which is detected line by line:
+//
+//   - TREC: "query_id iteration doc_id grade", separated by whitespace.
+//   - TSV: "query_id\tdoc_id\tgrade", or "query_id\tdoc_id" for a grade of 1.
+//
+// A header line whose grade is not an integer (as in the BEIR datasets) is
+// skipped.
+func ReadQrels(r io.Reader) (Qrels, error) {
+	qrels := make(Qrels)
+	err := readLines(r, func(lineNum int, line string) error {
+		var queryID, docID, grade string
+		if fields := strings.Split(line, "\t"); len(fields) == 2 || len(fields) == 3 {
+			queryID, docID, grade = fields[0], fields[1], "1"
+			if len(fields) == 3 {
+				grade = fields[2]
+			}
+		} else if fields := strings.Fields(line); len(fields) == 4 {
+			queryID, docID, grade = fields[0], fields[2], fields[3]
+		} else {
+			return fmt.Errorf("line %d: unknown qrels format", lineNum)
+		}
+
+		g, err := strconv.Atoi(strings.TrimSpace(grade))
+		if err != nil {
+			if lineNum == 1 {
+				return nil // The header.
+			}
+			return fmt.Errorf("line %d: bad grade %q", lineNum, grade)
+		}
+
+		if qrels[queryID] == nil {
+			qrels[queryID] = make(map[string]int)
+		}
+		qrels[queryID][docID] = g
+		return nil
+	})
+	return qrels, err
+}
+
+// WriteFields writes the fields to w as one line of TSV. The tabs and line
+// breaks within the fields are replaced by spaces, so that the line can be
+// read back by the readers of this package.
+func WriteFields(w io.Writer, fields ...string) error {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(fieldReplacer.Replace(f))
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// fieldReplacer replaces the separators of TSV within the fields.
+var fieldReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// readLines calls f with every non-empty line of r, without the trailing
+// line break.
+func readLines(r io.Reader, f func(lineNum int, line string) error) error {
+	scanner := bufio.NewScanner(r)
+	// Allow long paragraphs.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := f(lineNum, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
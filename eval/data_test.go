@@ -0,0 +1,92 @@
+package eval_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadCorpus(t *testing.T) {
+	in := "1\tTitle\tParagraph one.\n\n2\tParagraph two.\n"
+	got, err := eval.ReadCorpus(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []retrieval.Document{
+		{ID: "1", Title: "Title", Para: "Paragraph one."},
+		{ID: "2", Para: "Paragraph two."},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestReadQrels(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    eval.Qrels
+		wantErr bool
+	}{
+		{
+			name: "trec",
+			in:   "q1 0 d1 1\nq1 0 d2 0\nq2 0 d3 2\n",
+			want: eval.Qrels{"q1": {"d1": 1, "d2": 0}, "q2": {"d3": 2}},
+		},
+		{
+			name: "tsv with header",
+			in:   "query-id\tcorpus-id\tscore\nq1\td1\t1\nq2\td3\t2\n",
+			want: eval.Qrels{"q1": {"d1": 1}, "q2": {"d3": 2}},
+		},
+		{
+			name: "tsv without grades",
+			in:   "q1\td1\nq1\td2\n",
+			want: eval.Qrels{"q1": {"d1": 1, "d2": 1}},
+		},
+		{
+			name:    "bad grade",
+			in:      "q1\td1\t1\nq1\td2\tyes\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := eval.ReadQrels(strings.NewReader(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err: got %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(tt.want, got)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteFields(t *testing.T) {
+	var buf strings.Builder
+	if err := eval.WriteFields(&buf, "1", "Title\twith a tab", "Paragraph\r\none.\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The line is read back as it was written, except for the separators.
+	got, err := eval.ReadCorpus(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []retrieval.Document{
+		{ID: "1", Title: "Title with a tab", Para: "Paragraph one. "},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
@@ -0,0 +1,167 @@
+// Package eval measures the retrieval quality of the encoders on a labeled
+// corpus, by standard IR metrics: Recall@k, MRR@10, nDCG@10 and MAP.
+//
+// The corpus is retrieved by exhaustive dense search (see
+// retrieval.DenseIndex), and optionally reranked by a cross encoder, so
+// that the results only depend on the models.
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+type Config struct {
+	// The encoder of the corpus and the queries, such as
+	// *rocketqa.DualEncoder. Required.
+	Encoder retrieval.Encoder
+	// An optional ranker that reranks the retrieved documents, such as
+	// *rocketqa.CrossEncoder.
+	Ranker retrieval.Ranker
+	// The number of documents retrieved per query, which are all reranked
+	// if Ranker is set. Defaults to 100.
+	Depth int
+	// The cutoffs of Recall@k. Defaults to 1, 5, 10, 20, 50 and 100, except
+	// those beyond Depth.
+	RecallCutoffs []int
+	// The number of texts per call of the encoder. Defaults to 32.
+	BatchSize int
+}
+
+// Metrics maps the names of the metrics, such as "ndcg@10", to their values.
+type Metrics map[string]float64
+
+// QueryResult holds the results of one query.
+type QueryResult struct {
+	QueryID string
+	// The retrieved (and reranked, if Config.Ranker is set) documents, best
+	// first.
+	Hits    []retrieval.Hit
+	Metrics Metrics
+}
+
+// Report holds the results of an evaluation.
+type Report struct {
+	// The names of the metrics, in the order of reporting.
+	Names []string
+	// The means of the metrics over all the queries.
+	Mean Metrics
+	// The results of the queries, in the order of the input. Queries without
+	// relevance judgments are not evaluated.
+	Queries []QueryResult
+}
+
+// Evaluate retrieves the corpus for every query with relevance judgments,
+// and computes the metrics.
+func Evaluate(ctx context.Context, cfg *Config, corpus []retrieval.Document, queries []Query, qrels Qrels) (*Report, error) {
+	if cfg.Encoder == nil {
+		return nil, errors.New("no encoder")
+	}
+	depth := cfg.Depth
+	if depth <= 0 {
+		depth = 100
+	}
+	cutoffs := cfg.RecallCutoffs
+	if cutoffs == nil {
+		for _, k := range []int{1, 5, 10, 20, 50, 100} {
+			if k <= depth {
+				cutoffs = append(cutoffs, k)
+			}
+		}
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+
+	index := retrieval.NewDenseIndex(cfg.Encoder)
+	for start := 0; start < len(corpus); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		end := min(start+batchSize, len(corpus))
+		if err := index.Add(corpus[start:end]...); err != nil {
+			return nil, fmt.Errorf("encoding the corpus: %w", err)
+		}
+	}
+
+	var judged []Query
+	for _, q := range queries {
+		if _, ok := qrels[q.ID]; ok {
+			judged = append(judged, q)
+		}
+	}
+
+	report := &Report{
+		Names: metricNames(cutoffs),
+		Mean:  make(Metrics),
+	}
+	for start := 0; start < len(judged); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		batch := judged[start:min(start+batchSize, len(judged))]
+		texts := make([]string, len(batch))
+		for i, q := range batch {
+			texts[i] = q.Text
+		}
+		vectors, err := cfg.Encoder.EncodeQueries(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("encoding the queries: %w", err)
+		}
+		if len(vectors) != len(batch) {
+			return nil, fmt.Errorf("got %d query vectors, want %d", len(vectors), len(batch))
+		}
+
+		for i, q := range batch {
+			hits := index.RetrieveByVector(vectors[i], depth)
+			if cfg.Ranker != nil {
+				var err error
+				if hits, err = retrieval.Rerank(ctx, cfg.Ranker, q.Text, hits); err != nil {
+					return nil, fmt.Errorf("reranking query %s: %w", q.ID, err)
+				}
+			}
+
+			report.Queries = append(report.Queries, QueryResult{
+				QueryID: q.ID,
+				Hits:    hits,
+				Metrics: computeMetrics(hits, qrels[q.ID], cutoffs),
+			})
+		}
+	}
+
+	for _, r := range report.Queries {
+		for name, value := range r.Metrics {
+			report.Mean[name] += value / float64(len(report.Queries))
+		}
+	}
+	return report, nil
+}
+
+func metricNames(cutoffs []int) []string {
+	var names []string
+	for _, k := range cutoffs {
+		names = append(names, fmt.Sprintf("recall@%d", k))
+	}
+	return append(names, "mrr@10", "ndcg@10", "map")
+}
+
+func computeMetrics(hits []retrieval.Hit, grades map[string]int, cutoffs []int) Metrics {
+	ranked := make([]string, len(hits))
+	for i, h := range hits {
+		ranked[i] = h.ID
+	}
+
+	m := make(Metrics)
+	for _, k := range cutoffs {
+		m[fmt.Sprintf("recall@%d", k)] = Recall(ranked, grades, k)
+	}
+	m["mrr@10"] = ReciprocalRank(ranked, grades, 10)
+	m["ndcg@10"] = NDCG(ranked, grades, 10)
+	m["map"] = AveragePrecision(ranked, grades)
+	return m
+}
@@ -0,0 +1,152 @@
+package eval_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/retrieval/retrievaltest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvaluate(t *testing.T) {
+	corpus := []retrieval.Document{
+		{ID: "d1", Para: "p1"},
+		{ID: "d2", Para: "p2"},
+		{ID: "d3", Para: "p3"},
+	}
+	queries := []eval.Query{
+		{ID: "q1", Text: "q1"},
+		{ID: "q2", Text: "q2"},
+		{ID: "q3", Text: "q3"}, // Not judged.
+	}
+	qrels := eval.Qrels{
+		"q1": {"d1": 1},
+		"q2": {"d3": 1},
+	}
+	enc := retrievaltest.Encoder{
+		"p1": {1, 0},
+		"p2": {0.8, 0.6},
+		"p3": {0, 1},
+		"q1": {1, 0}, // Ranks d1, d2, d3.
+		"q2": {1, 0}, // Ranks d1, d2, d3.
+		"q3": {0, 1},
+	}
+
+	tests := []struct {
+		name   string
+		ranker retrieval.Ranker
+		want   eval.Metrics
+	}{
+		{
+			name: "dense",
+			want: eval.Metrics{
+				"recall@1": 0.5,
+				"recall@2": 0.5,
+				"mrr@10":   (1 + 1.0/3) / 2,
+				"ndcg@10":  (1 + 0.5) / 2,
+				"map":      (1 + 1.0/3) / 2,
+			},
+		},
+		{
+			name:   "reranked",
+			ranker: retrievaltest.Ranker{"p1": 0.1, "p2": 0.2, "p3": 0.9}, // Ranks d3, d2, d1.
+			want: eval.Metrics{
+				"recall@1": 0.5,
+				"recall@2": 0.5,
+				"mrr@10":   (1.0/3 + 1) / 2,
+				"ndcg@10":  (0.5 + 1) / 2,
+				"map":      (1.0/3 + 1) / 2,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := eval.Evaluate(context.Background(), &eval.Config{
+				Encoder:       enc,
+				Ranker:        tt.ranker,
+				RecallCutoffs: []int{1, 2},
+				BatchSize:     2,
+			}, corpus, queries, qrels)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(report.Queries) != 2 {
+				t.Fatalf("got %d queries, want 2", len(report.Queries))
+			}
+			if !cmp.Equal(report.Mean, tt.want, cmp.Comparer(approxEqual)) {
+				diff := cmp.Diff(tt.want, report.Mean)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestReport_WritePerQuery(t *testing.T) {
+	report := &eval.Report{
+		Names: []string{"mrr@10", "map"},
+		Mean:  eval.Metrics{"mrr@10": 0.75, "map": 0.5},
+		Queries: []eval.QueryResult{
+			{QueryID: "q1", Metrics: eval.Metrics{"mrr@10": 1, "map": 0.5}},
+			{QueryID: "q2", Metrics: eval.Metrics{"mrr@10": 0.5, "map": 0.5}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := report.WritePerQuery(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := eval.ReadPerQuery(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]eval.Metrics{
+		"q1": {"mrr@10": 1, "map": 0.5},
+		"q2": {"mrr@10": 0.5, "map": 0.5},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestReport_WriteRun(t *testing.T) {
+	hits := []retrieval.Hit{
+		{Document: retrieval.Document{ID: "d2", Para: "p2"}, Score: 0.9},
+		{Document: retrieval.Document{ID: "d1", Para: "p1"}, Score: 0.5},
+	}
+	report := &eval.Report{
+		Queries: []eval.QueryResult{{QueryID: "q1", Hits: hits}},
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteRun(&buf, "test"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := eval.ReadRun(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the IDs and the scores are kept.
+	want := map[string][]retrieval.Hit{
+		"q1": {
+			{Document: retrieval.Document{ID: "d2"}, Score: 0.9},
+			{Document: retrieval.Document{ID: "d1"}, Score: 0.5},
+		},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	const eps = 1e-6
+	return a-b < eps && b-a < eps
+}
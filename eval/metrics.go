@@ -0,0 +1,111 @@
+package eval
+
+import (
+	"math"
+	"sort"
+)
+
+// The metrics below take the IDs of the retrieved documents, best first, and
+// the relevance grades of the query (see Qrels). A document is relevant if
+// its grade is positive. They return 0 for queries without relevant
+// documents.
+
+// Recall returns the fraction of the relevant documents within the top k.
+func Recall(ranked []string, grades map[string]int, k int) float64 {
+	relevant := countRelevant(grades)
+	if relevant == 0 {
+		return 0
+	}
+
+	var found int
+	for _, id := range cutoff(ranked, k) {
+		if grades[id] > 0 {
+			found++
+		}
+	}
+	return float64(found) / float64(relevant)
+}
+
+// ReciprocalRank returns the reciprocal of the rank of the first relevant
+// document within the top k, or 0 if there is none. Its mean over queries
+// is MRR.
+func ReciprocalRank(ranked []string, grades map[string]int, k int) float64 {
+	for i, id := range cutoff(ranked, k) {
+		if grades[id] > 0 {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// NDCG returns the normalized discounted cumulative gain of the top k, where
+// the gain of a document is its grade (as in trec_eval), and the gain at
+// rank i is discounted by log2(i+1).
+func NDCG(ranked []string, grades map[string]int, k int) float64 {
+	var dcg float64
+	for i, id := range cutoff(ranked, k) {
+		if g := grades[id]; g > 0 {
+			dcg += float64(g) / math.Log2(float64(i+2))
+		}
+	}
+
+	// The ideal ranking lists the relevant documents by descending grades.
+	var ideal []int
+	for _, g := range grades {
+		if g > 0 {
+			ideal = append(ideal, g)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ideal)))
+
+	var idcg float64
+	for i, g := range ideal {
+		if k > 0 && i >= k {
+			break
+		}
+		idcg += float64(g) / math.Log2(float64(i+2))
+	}
+
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// AveragePrecision returns the mean of the precisions at the ranks of the
+// relevant documents, where the relevant documents not retrieved count as
+// zero. Its mean over queries is MAP.
+func AveragePrecision(ranked []string, grades map[string]int) float64 {
+	relevant := countRelevant(grades)
+	if relevant == 0 {
+		return 0
+	}
+
+	var found int
+	var sum float64
+	for i, id := range ranked {
+		if grades[id] > 0 {
+			found++
+			sum += float64(found) / float64(i+1)
+		}
+	}
+	return sum / float64(relevant)
+}
+
+func countRelevant(grades map[string]int) int {
+	var n int
+	for _, g := range grades {
+		if g > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// cutoff returns the top k of ranked, or all of them if k <= 0.
+func cutoff(ranked []string, k int) []string {
+	if k > 0 && len(ranked) > k {
+		return ranked[:k]
+	}
+	return ranked
+}
@@ -0,0 +1,71 @@
+package eval_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-aie/rocketqa/eval"
+)
+
+func TestMetrics(t *testing.T) {
+	ranked := []string{"d1", "d2", "d3", "d4"}
+
+	tests := []struct {
+		name   string
+		grades map[string]int
+		metric func(ranked []string, grades map[string]int) float64
+		want   float64
+	}{
+		{
+			name:   "recall@2",
+			grades: map[string]int{"d2": 1, "d4": 1, "d5": 1},
+			metric: func(r []string, g map[string]int) float64 { return eval.Recall(r, g, 2) },
+			want:   1.0 / 3,
+		},
+		{
+			name:   "recall of no relevant documents",
+			grades: map[string]int{"d1": 0},
+			metric: func(r []string, g map[string]int) float64 { return eval.Recall(r, g, 10) },
+			want:   0,
+		},
+		{
+			name:   "reciprocal rank",
+			grades: map[string]int{"d3": 1, "d4": 2},
+			metric: func(r []string, g map[string]int) float64 { return eval.ReciprocalRank(r, g, 10) },
+			want:   1.0 / 3,
+		},
+		{
+			name:   "reciprocal rank beyond the cutoff",
+			grades: map[string]int{"d3": 1},
+			metric: func(r []string, g map[string]int) float64 { return eval.ReciprocalRank(r, g, 2) },
+			want:   0,
+		},
+		{
+			name:   "ndcg of the ideal ranking",
+			grades: map[string]int{"d1": 2, "d2": 1},
+			metric: func(r []string, g map[string]int) float64 { return eval.NDCG(r, g, 10) },
+			want:   1,
+		},
+		{
+			name:   "ndcg of a swapped ranking",
+			grades: map[string]int{"d1": 1, "d2": 2},
+			metric: func(r []string, g map[string]int) float64 { return eval.NDCG(r, g, 10) },
+			want:   (1 + 2/math.Log2(3)) / (2 + 1/math.Log2(3)),
+		},
+		{
+			name:   "average precision",
+			grades: map[string]int{"d1": 1, "d3": 1, "d9": 1},
+			metric: func(r []string, g map[string]int) float64 { return eval.AveragePrecision(r, g) },
+			want:   (1 + 2.0/3) / 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.metric(ranked, tt.grades)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package eval
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+// WriteRun writes the retrieved documents of all the queries in the TREC run
+// format ("query_id Q0 doc_id rank score tag"), which is understood by
+// trec_eval and most IR toolkits.
+func (r *Report) WriteRun(w io.Writer, tag string) error {
+	bw := bufio.NewWriter(w)
+	for _, q := range r.Queries {
+		for i, h := range q.Hits {
+			fmt.Fprintf(bw, "%s Q0 %s %d %g %s\n", q.QueryID, h.ID, i+1, h.Score, tag)
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadRun reads a run in the TREC run format (see WriteRun), and returns the
+// retrieved documents by query ID, in the order of their ranks. Only the IDs
+// and the scores of the documents are set.
+func ReadRun(r io.Reader) (map[string][]retrieval.Hit, error) {
+	type ranked struct {
+		hit  retrieval.Hit
+		rank int
+	}
+	byQuery := make(map[string][]ranked)
+
+	err := readLines(r, func(lineNum int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 6 {
+			return fmt.Errorf("line %d: got %d fields, want 6", lineNum, len(fields))
+		}
+
+		rank, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("line %d: bad rank %q", lineNum, fields[3])
+		}
+		score, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return fmt.Errorf("line %d: bad score %q", lineNum, fields[4])
+		}
+
+		queryID := fields[0]
+		byQuery[queryID] = append(byQuery[queryID], ranked{
+			hit:  retrieval.Hit{Document: retrieval.Document{ID: fields[2]}, Score: score},
+			rank: rank,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	run := make(map[string][]retrieval.Hit, len(byQuery))
+	for queryID, rs := range byQuery {
+		sort.SliceStable(rs, func(i, j int) bool {
+			return rs[i].rank < rs[j].rank
+		})
+		hits := make([]retrieval.Hit, len(rs))
+		for i, r := range rs {
+			hits[i] = r.hit
+		}
+		run[queryID] = hits
+	}
+	return run, nil
+}
+
+// WritePerQuery writes the metrics of every query in the format of
+// "trec_eval -q" ("metric\tquery_id\tvalue"), followed by the means with
+// "all" as the query ID. Use ReadPerQuery to read them back, e.g. for
+// comparing two runs query by query.
+func (r *Report) WritePerQuery(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, q := range r.Queries {
+		for _, name := range r.Names {
+			fmt.Fprintf(bw, "%s\t%s\t%.6f\n", name, q.QueryID, q.Metrics[name])
+		}
+	}
+	for _, name := range r.Names {
+		fmt.Fprintf(bw, "%s\tall\t%.6f\n", name, r.Mean[name])
+	}
+	return bw.Flush()
+}
+
+// ReadPerQuery reads the per-query metrics written by WritePerQuery (or by
+// "trec_eval -q"), and returns them by query ID. The means are skipped.
+func ReadPerQuery(r io.Reader) (map[string]Metrics, error) {
+	perQuery := make(map[string]Metrics)
+	err := readLines(r, func(lineNum int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("line %d: got %d fields, want 3", lineNum, len(fields))
+		}
+
+		name, queryID := fields[0], fields[1]
+		if queryID == "all" {
+			return nil
+		}
+		value, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("line %d: bad value %q", lineNum, fields[2])
+		}
+
+		if perQuery[queryID] == nil {
+			perQuery[queryID] = make(Metrics)
+		}
+		perQuery[queryID][name] = value
+		return nil
+	})
+	return perQuery, err
+}
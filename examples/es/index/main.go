@@ -2,85 +2,69 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
-	"crypto/tls"
-	"encoding/json"
+	"errors"
 	"flag"
-	"log"
-	"net/http"
+	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/esutil"
+	es "github.com/elastic/go-elasticsearch/v8"
 	"github.com/go-aie/rocketqa"
+	"github.com/go-aie/rocketqa/diskcache"
+	"github.com/go-aie/rocketqa/store/elasticsearch"
 )
 
 type Indexer struct {
-	es *elasticsearch.Client
-	de *rocketqa.DualEncoder
+	store *elasticsearch.Store
+	de    elasticsearch.ParaEncoder
 }
 
-func NewIndexer(es *elasticsearch.Client, de *rocketqa.DualEncoder) *Indexer {
+func NewIndexer(store *elasticsearch.Store, de elasticsearch.ParaEncoder) *Indexer {
 	return &Indexer{
-		es: es,
-		de: de,
+		store: store,
+		de:    de,
 	}
 }
 
-func (i *Indexer) Index(index string, qptsC <-chan rocketqa.QPTs) error {
-	bulk, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Client: i.es,
-		Index:  index,
-	})
-	if err != nil {
-		return err
-	}
-
-	ctx := context.Background()
-
-	var baseIdx int
+// Index indexes all the batches received from qptsC. The documents rejected
+// by Elasticsearch are logged and skipped, while other errors abort the
+// indexing.
+func (i *Indexer) Index(ctx context.Context, qptsC <-chan rocketqa.QPTs) error {
+	var baseIdx, failed int
 	for qpts := range qptsC {
-		vectors, _ := i.de.EncodePara(qpts.P(), qpts.T())
-
+		var docs []elasticsearch.Document
 		for idx, item := range qpts {
-			vector := vectors[idx].Norm().ToFloat64()
-
-			b, err := json.Marshal(map[string]interface{}{
-				"title":     item.Title,
-				"paragraph": item.Para,
-				"vector":    vector,
+			docs = append(docs, elasticsearch.Document{
+				ID:        strconv.Itoa(baseIdx + idx + 1),
+				Title:     item.Title,
+				Paragraph: item.Para,
 			})
-			if err != nil {
-				return err
-			}
+		}
 
-			item := esutil.BulkIndexerItem{
-				Action:     "index",
-				DocumentID: strconv.Itoa(baseIdx + idx + 1),
-				Body:       bytes.NewReader(b),
-				OnSuccess: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem) {
-					log.Printf("[%d] %s test/%s\n", res.Status, res.Result, item.DocumentID)
-				},
-				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						log.Printf("ERROR: %s\n", err)
-					} else {
-						log.Printf("ERROR: %s: %s\n", res.Error.Type, res.Error.Reason)
-					}
-				},
-			}
-			if err = bulk.Add(ctx, item); err != nil {
-				return err
+		// Tag the logs of the encoder with the range of the batch.
+		batchCtx := rocketqa.WithRequestID(ctx, fmt.Sprintf("docs-%d-%d", baseIdx+1, baseIdx+len(qpts)))
+		err := i.store.EncodeAndUpsert(batchCtx, i.de, docs)
+		var bulkErr *elasticsearch.BulkError
+		switch {
+		case errors.As(err, &bulkErr):
+			// Report the failed documents and move on.
+			for _, f := range bulkErr.Failures {
+				slog.ErrorContext(batchCtx, "failed to index document", "id", f.ID, "type", f.Type, "reason", f.Reason)
 			}
+			failed += len(bulkErr.Failures)
+		case err != nil:
+			return err
 		}
 
 		baseIdx += len(qpts)
+		slog.InfoContext(ctx, "indexed documents", "total", baseIdx, "failed", failed)
 	}
 
-	return bulk.Close(ctx)
+	return nil
 }
 
 type Reader struct {
@@ -101,21 +85,27 @@ func NewReader(dataFile string, batchSize int) *Reader {
 	}
 }
 
-func (r *Reader) Read() {
+// Read sends the records of the data file to C in batches, and closes C when
+// done, even on errors.
+func (r *Reader) Read() error {
+	defer close(r.C)
+
 	file, err := os.Open(r.dataFile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 
 	var qpts []rocketqa.QPT
 
+	var lineNum int
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		lineNum++
 		line := scanner.Text()
 		parts := strings.Split(strings.TrimSpace(line), "\t")
 		if len(parts) != 2 {
-			log.Printf("bad line %q", line)
+			slog.Warn("skipped bad line", "line", lineNum, "text", line)
 			continue
 		}
 
@@ -140,36 +130,47 @@ func (r *Reader) Read() {
 		r.C <- qpts
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-	}
-
-	close(r.C)
+	return scanner.Err()
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var indexName, dataFile string
+	var createIndex bool
+	var esCfg es.Config
 	flag.StringVar(&indexName, "index", "", "The index name")
 	flag.StringVar(&dataFile, "data", "", "The data file")
+	flag.BoolVar(&createIndex, "create", false, "Whether to create the index first")
+	flag.Func("addr", "The Elasticsearch address (repeatable, defaults to $ELASTICSEARCH_URL)", func(s string) error {
+		esCfg.Addresses = append(esCfg.Addresses, s)
+		return nil
+	})
+	flag.StringVar(&esCfg.Username, "user", os.Getenv("ES_USERNAME"), "The Elasticsearch username")
+	flag.StringVar(&esCfg.Password, "password", os.Getenv("ES_PASSWORD"), "The Elasticsearch password")
+	caCert := flag.String("cacert", "", "The path to the CA certificate of Elasticsearch")
+	cacheFile := flag.String("cache", "", "The path to the embedding cache file, which saves re-encoding unchanged paragraphs")
+	slowThreshold := flag.Duration("slow", time.Second, "The threshold above which the inferences are logged as slow")
 	flag.Parse()
 
 	if indexName == "" {
-		log.Fatalf(`argument "index" is required`)
+		return errors.New(`argument "index" is required`)
 	}
 	if dataFile == "" {
-		log.Fatalf(`argument "data" is required`)
+		return errors.New(`argument "data" is required`)
 	}
 
-	es, err := elasticsearch.NewClient(elasticsearch.Config{
-		Addresses: []string{"https://localhost:9200"},
-		Username:  "elastic",
-		Password:  "123456",
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
+	if *caCert != "" {
+		cert, err := os.ReadFile(*caCert)
+		if err != nil {
+			return err
+		}
+		esCfg.CACert = cert
 	}
 
 	de, err := rocketqa.NewDualEncoder(&rocketqa.DualEncoderConfig{
@@ -180,16 +181,63 @@ func main() {
 		QueryMaxSeqLength: 32,
 		ParaMaxSeqLength:  384,
 		ForCN:             true,
+		Normalize:         true, // The index uses dot_product similarity
+		Hooks: rocketqa.NewLogHooks(&rocketqa.LogHooksConfig{
+			SlowThreshold: *slowThreshold,
+		}),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer de.Close()
 
-	reader := NewReader(dataFile, 100)
-	go reader.Read()
+	store, err := elasticsearch.New(&elasticsearch.Config{
+		Client:      esCfg,
+		Index:       indexName,
+		Fingerprint: de.Fingerprint(),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if createIndex {
+		if err := store.CreateIndex(ctx); err != nil {
+			return err
+		}
+	} else if err := store.CheckFingerprint(ctx); err != nil {
+		return err
+	}
+
+	var enc elasticsearch.ParaEncoder = de
+	if *cacheFile != "" {
+		cache, err := diskcache.Open(&diskcache.Config{
+			Path:    *cacheFile,
+			Encoder: de,
+		})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			stats := cache.Stats()
+			slog.Info("embedding cache", "hits", stats.Hits, "misses", stats.Misses, "hit_rate", stats.HitRate())
+			cache.Close()
+		}()
+		enc = cache
+	}
 
-	indexer := NewIndexer(es, de)
-	if err := indexer.Index(indexName, reader.C); err != nil {
-		log.Fatal(err)
+	reader := NewReader(dataFile, 100)
+	readErrC := make(chan error, 1)
+	go func() {
+		readErrC <- reader.Read()
+	}()
+
+	indexer := NewIndexer(store, enc)
+	if err := indexer.Index(ctx, reader.C); err != nil {
+		// Unblock the reader.
+		for range reader.C {
+		}
+		return err
 	}
+	return <-readErrC
 }
@@ -3,19 +3,18 @@ package main
 import (
 	"bufio"
 	"context"
-	"crypto/tls"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/core/knnsearch"
-	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	es "github.com/elastic/go-elasticsearch/v8"
 	"github.com/go-aie/rocketqa"
-	"golang.org/x/exp/slices"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/store/elasticsearch"
 )
 
 type Candidate struct {
@@ -26,102 +25,112 @@ type Candidate struct {
 }
 
 type Querier struct {
-	es *elasticsearch.Client
-	de *rocketqa.DualEncoder
-	ce *rocketqa.CrossEncoder
+	retriever retrieval.Retriever
+	ce        *rocketqa.CrossEncoder
 }
 
-func NewQuerier(es *elasticsearch.Client, de *rocketqa.DualEncoder, ce *rocketqa.CrossEncoder) *Querier {
+// NewQuerier creates a querier that retrieves candidates by both full-text
+// search and kNN search, and combines them by the given fusion method.
+func NewQuerier(store *elasticsearch.Store, de *rocketqa.DualEncoder, ce *rocketqa.CrossEncoder, fusion retrieval.Fusion) *Querier {
 	return &Querier{
-		es: es,
-		de: de,
+		retriever: &retrieval.Hybrid{
+			Retrievers: []retrieval.Retriever{
+				store.LexicalRetriever(),
+				store.DenseRetriever(de),
+			},
+			Fusion: fusion,
+			Depth:  20,
+		},
 		ce: ce,
 	}
 }
 
-func (q *Querier) Search(index, query string) []*Candidate {
-	vectors := q.de.EncodeQuery([]string{query})
-	vector := vectors[0].Norm().ToFloat64()
-
-	ks := knnsearch.New(q.es)
-	ks.Index(index).Request(&knnsearch.Request{
-		Knn: types.CoreKnnQuery{
-			Field:         "vector",
-			QueryVector:   vector,
-			K:             10,
-			NumCandidates: 100,
-		},
-	})
-	resp, err := ks.Do(context.Background())
+func (q *Querier) Search(ctx context.Context, query string) ([]*Candidate, error) {
+	hits, err := q.retriever.Retrieve(ctx, query, 10)
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	var v map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var candidates []*Candidate
-
-	if v["hits"] == nil {
-		return candidates
-	}
-
-	hits := v["hits"].(map[string]interface{})["hits"].([]interface{})
 	for _, h := range hits {
-		doc := h.(map[string]interface{})
-		source := doc["_source"].(map[string]interface{})
 		candidates = append(candidates, &Candidate{
-			Title: source["title"].(string),
-			Para:  source["paragraph"].(string),
+			Title: h.Title,
+			Para:  h.Para,
 		})
 	}
 
-	return candidates
+	return candidates, nil
 }
 
-func (q *Querier) Sort(query string, candidates []*Candidate) {
-	var qpts rocketqa.QPTs
+func (q *Querier) Sort(ctx context.Context, query string, candidates []*Candidate) ([]*Candidate, error) {
+	var cs []rocketqa.Candidate
 	for _, c := range candidates {
-		qpts = append(qpts, rocketqa.QPT{
-			Query: query,
-			Para:  c.Para,
-			Title: c.Title,
-		})
+		cs = append(cs, rocketqa.Candidate{Title: c.Title, Para: c.Para})
 	}
 
-	scores, _ := q.ce.Rank(qpts.Q(), qpts.P(), qpts.T())
-	for i, score := range scores {
-		candidates[i].Score = score
+	results, err := q.ce.Rerank(ctx, query, cs, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	slices.SortFunc(candidates, func(a, b *Candidate) bool {
-		return a.Score > b.Score
-	})
+	var sorted []*Candidate
+	for _, r := range results {
+		c := candidates[r.Index]
+		c.Score = r.Score
+		sorted = append(sorted, c)
+	}
+	return sorted, nil
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	var indexName string
+	var esCfg es.Config
 	flag.StringVar(&indexName, "index", "", "The index name")
+	flag.Func("addr", "The Elasticsearch address (repeatable, defaults to $ELASTICSEARCH_URL)", func(s string) error {
+		esCfg.Addresses = append(esCfg.Addresses, s)
+		return nil
+	})
+	flag.StringVar(&esCfg.Username, "user", os.Getenv("ES_USERNAME"), "The Elasticsearch username")
+	flag.StringVar(&esCfg.Password, "password", os.Getenv("ES_PASSWORD"), "The Elasticsearch password")
+	caCert := flag.String("cacert", "", "The path to the CA certificate of Elasticsearch")
+	fusionName := flag.String("fusion", "rrf", `The method to combine the full-text and kNN results, either "rrf" or "weighted"`)
+	slowThreshold := flag.Duration("slow", 500*time.Millisecond, "The threshold above which the inferences are logged as slow")
 	flag.Parse()
 
 	if indexName == "" {
-		log.Fatalf(`argument "index" is required`)
+		return errors.New(`argument "index" is required`)
 	}
 
-	es, err := elasticsearch.NewClient(elasticsearch.Config{
-		Addresses: []string{"https://localhost:9200"},
-		Username:  "elastic",
-		Password:  "123456",
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
+	var fusion retrieval.Fusion
+	switch *fusionName {
+	case "rrf":
+		fusion = retrieval.RRF{}
+	case "weighted":
+		fusion = retrieval.WeightedSum{Weights: []float64{0.3, 0.7}}
+	default:
+		return fmt.Errorf(`unknown fusion method %q`, *fusionName)
+	}
+
+	if *caCert != "" {
+		cert, err := os.ReadFile(*caCert)
+		if err != nil {
+			return err
+		}
+		esCfg.CACert = cert
 	}
 
+	// Log the failed and the slow inferences, along with the query IDs.
+	hooks := rocketqa.NewLogHooks(&rocketqa.LogHooksConfig{
+		SlowThreshold: *slowThreshold,
+	})
+
 	de, err := rocketqa.NewDualEncoder(&rocketqa.DualEncoderConfig{
 		ModelPath:         "../../../testdata/zh_dureader_de_v2.pdmodel",
 		ParamsPath:        "../../../testdata/zh_dureader_de_v2.pdiparams",
@@ -130,9 +139,25 @@ func main() {
 		QueryMaxSeqLength: 32,
 		ParaMaxSeqLength:  384,
 		ForCN:             true,
+		Normalize:         true, // The index uses dot_product similarity
+		Hooks:             hooks,
+	})
+	if err != nil {
+		return err
+	}
+	defer de.Close()
+
+	store, err := elasticsearch.New(&elasticsearch.Config{
+		Client:      esCfg,
+		Index:       indexName,
+		Fingerprint: de.Fingerprint(),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	// Refuse to search an index built by another model.
+	if err := store.CheckFingerprint(context.Background()); err != nil {
+		return err
 	}
 
 	ce, err := rocketqa.NewCrossEncoder(&rocketqa.CrossEncoderConfig{
@@ -142,34 +167,53 @@ func main() {
 		DoLowerCase:  true,
 		MaxSeqLength: 384,
 		ForCN:        true,
+		WarmUp:       true,
+		Hooks:        hooks,
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+	defer ce.Close()
+	report := ce.WarmUpReport()
+	slog.Info("warmed up", "runs", report.Runs, "predictors", report.Predictors, "duration", report.Duration)
 
-	querier := NewQuerier(es, de, ce)
+	querier := NewQuerier(store, de, ce, fusion)
 	fmt.Print("Query: ")
 
+	var queryNum int
 	scanner := bufio.NewScanner(os.Stdin)
 	for scanner.Scan() {
-		query := scanner.Text()
-
-		candidates := querier.Search(indexName, query)
-		fmt.Println("Candidates:")
-		for _, c := range candidates {
-			fmt.Printf("%s\t%s\n", c.Title, c.Para)
-		}
+		queryNum++
+		ctx := rocketqa.WithRequestID(context.Background(), "query-"+strconv.Itoa(queryNum))
 
-		fmt.Println("Answers:")
-		querier.Sort(query, candidates)
-		for _, c := range candidates {
-			fmt.Printf("%s\t%s\t%v\n", c.Title, c.Para, c.Score)
+		// A failed query is reported, and does not stop the loop.
+		if err := answer(ctx, querier, scanner.Text()); err != nil {
+			slog.ErrorContext(ctx, "query failed", "request_id", rocketqa.RequestID(ctx), "error", err)
 		}
 
 		fmt.Print("Query: ")
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+	return scanner.Err()
+}
+
+func answer(ctx context.Context, querier *Querier, query string) error {
+	candidates, err := querier.Search(ctx, query)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Candidates:")
+	for _, c := range candidates {
+		fmt.Printf("%s\t%s\n", c.Title, c.Para)
+	}
+
+	fmt.Println("Answers:")
+	answers, err := querier.Sort(ctx, query, candidates)
+	if err != nil {
+		return err
+	}
+	for _, c := range answers {
+		fmt.Printf("%s\t%s\t%v\n", c.Title, c.Para, c.Score)
 	}
+	return nil
 }
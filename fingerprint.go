@@ -0,0 +1,48 @@
+package rocketqa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-aie/rocketqa/fingerprint"
+)
+
+// ErrFingerprintMismatch is returned when vectors from encoders with
+// different fingerprints would be mixed, e.g. when searching an index built
+// by another model.
+var ErrFingerprintMismatch = fingerprint.ErrMismatch
+
+// transformFingerprint returns the fingerprint of t prefixed by its type, or
+// only its type if t has no Fingerprint method.
+func transformFingerprint(t Transformer) string {
+	if f, ok := t.(interface{ Fingerprint() string }); ok {
+		return fmt.Sprintf("%T:%s", t, f.Fingerprint())
+	}
+	return fmt.Sprintf("%T", t)
+}
+
+// modelFingerprint returns the hex-encoded SHA-256 hash of the contents of
+// the model files and the settings.
+func modelFingerprint(files []string, settings ...any) (string, error) {
+	h := sha256.New()
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		n, err := io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+		// Delimit the files by their sizes.
+		fmt.Fprintf(h, "|%d|", n)
+	}
+	for _, s := range settings {
+		fmt.Fprintf(h, "%v|", s)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,15 @@
+// Package fingerprint holds what is shared by the encoders and the indexes
+// and stores that check the fingerprints of their vectors (see
+// rocketqa.DualEncoder.Fingerprint). It has no dependencies, so the indexes
+// and stores can use it without depending on the inference engine (and thus
+// cgo).
+package fingerprint
+
+import (
+	"errors"
+)
+
+// ErrMismatch is returned when vectors from encoders with different
+// fingerprints would be mixed, e.g. when searching an index built by another
+// model. It is aliased as rocketqa.ErrFingerprintMismatch.
+var ErrMismatch = errors.New("fingerprint mismatch")
@@ -0,0 +1,141 @@
+package rocketqa
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Op is the name of an instrumented operation.
+type Op string
+
+const (
+	OpEncodeQuery Op = "EncodeQuery"
+	OpEncodePara  Op = "EncodePara"
+	// OpRank covers Rank, RankQuery, RankTokenized and Rerank.
+	OpRank   Op = "Rank"
+	OpLogits Op = "Logits"
+)
+
+// CallStats describes one call of an encoder operation.
+type CallStats struct {
+	Op Op
+	// The number of inputs.
+	BatchSize int
+	// The number of tokens fed to the model, without and with padding.
+	Tokens, PaddedTokens int
+	// The number of inputs truncated to fit the maximum sequence length.
+	Truncated int
+
+	// The durations of the phases, whose sum is about Duration.
+	Tokenize  time.Duration
+	Pad       time.Duration
+	QueueWait time.Duration // Waiting for an idle predictor in the pool.
+	Inference time.Duration
+	// The total duration of the call.
+	Duration time.Duration
+
+	// The error of the call, if any. A panic is reported as an error before
+	// being propagated.
+	Err error
+}
+
+// Hooks receives the instrumentation events of the encoders, e.g. for
+// exporting metrics or traces. Implementations must be safe for concurrent
+// use. See the modules under contrib for Prometheus and OpenTelemetry.
+type Hooks interface {
+	// CallStarted is called at the start of every call, and returns the
+	// context to be passed to CallFinished (e.g. carrying a span).
+	CallStarted(ctx context.Context, op Op) context.Context
+	// CallFinished is called at the end of every call.
+	CallFinished(ctx context.Context, stats CallStats)
+}
+
+// phase is one of the phases in CallStats.
+type phase int
+
+const (
+	phaseTokenize phase = iota
+	phasePad
+)
+
+// call tracks the stats of one call. All its methods are no-ops on a nil
+// call, which is used when there are no hooks.
+type call struct {
+	hooks Hooks
+	ctx   context.Context
+	stats CallStats
+	start time.Time
+	last  time.Time
+}
+
+func startCall(ctx context.Context, hooks Hooks, op Op, batchSize int) *call {
+	if hooks == nil {
+		return nil
+	}
+
+	now := time.Now()
+	return &call{
+		hooks: hooks,
+		ctx:   hooks.CallStarted(ctx, op),
+		stats: CallStats{Op: op, BatchSize: batchSize},
+		start: now,
+		last:  now,
+	}
+}
+
+// mark adds the time since the last mark to the given phase.
+func (c *call) mark(p phase) {
+	if c == nil {
+		return
+	}
+
+	now := time.Now()
+	switch p {
+	case phaseTokenize:
+		c.stats.Tokenize += now.Sub(c.last)
+	case phasePad:
+		c.stats.Pad += now.Sub(c.last)
+	}
+	c.last = now
+}
+
+func (c *call) addTokens(tokens, paddedTokens, truncated int) {
+	if c == nil {
+		return
+	}
+	c.stats.Tokens += tokens
+	c.stats.PaddedTokens += paddedTokens
+	c.stats.Truncated += truncated
+}
+
+func (c *call) addInference(queueWait, inference time.Duration) {
+	if c == nil {
+		return
+	}
+	c.stats.QueueWait += queueWait
+	c.stats.Inference += inference
+	c.last = time.Now()
+}
+
+// end reports the stats, along with *err if err is not nil. It must be
+// deferred directly, so that it can report panics.
+func (c *call) end(err *error) {
+	if c == nil {
+		return
+	}
+
+	r := recover()
+	switch {
+	case r != nil:
+		c.stats.Err = fmt.Errorf("panic: %v", r)
+	case err != nil:
+		c.stats.Err = *err
+	}
+	c.stats.Duration = time.Since(c.start)
+	c.hooks.CallFinished(c.ctx, c.stats)
+
+	if r != nil {
+		panic(r)
+	}
+}
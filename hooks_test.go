@@ -0,0 +1,114 @@
+package rocketqa_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+type ctxKey struct{}
+
+// recordingHooks records the stats of all the calls.
+type recordingHooks struct {
+	mu    sync.Mutex
+	stats []rocketqa.CallStats
+}
+
+func (h *recordingHooks) CallStarted(ctx context.Context, op rocketqa.Op) context.Context {
+	return context.WithValue(ctx, ctxKey{}, op)
+}
+
+func (h *recordingHooks) CallFinished(ctx context.Context, stats rocketqa.CallStats) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The context from CallStarted is passed through.
+	if ctx.Value(ctxKey{}) != stats.Op {
+		stats.Op = "unexpected context"
+	}
+	h.stats = append(h.stats, stats)
+}
+
+func TestHooks_WarmUp(t *testing.T) {
+	hooks := new(recordingHooks)
+
+	deCfg := newDualEncoderConfig(2)
+	deCfg.WarmUp = true
+	deCfg.Hooks = hooks
+	if _, err := rocketqa.NewDualEncoder(deCfg); err != nil {
+		t.Fatal(err)
+	}
+	ceCfg := newCrossEncoderConfig(2)
+	ceCfg.WarmUp = true
+	ceCfg.Hooks = hooks
+	if _, err := rocketqa.NewCrossEncoder(ceCfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// The warm-up calls are not reported.
+	if len(hooks.stats) != 0 {
+		t.Errorf("Got %d calls, want none", len(hooks.stats))
+	}
+}
+
+func TestHooks(t *testing.T) {
+	hooks := new(recordingHooks)
+
+	deCfg := newDualEncoderConfig(1)
+	deCfg.Hooks = hooks
+	de, err := rocketqa.NewDualEncoder(deCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ceCfg := newCrossEncoderConfig(1)
+	ceCfg.Hooks = hooks
+	ce, err := rocketqa.NewCrossEncoder(ceCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	de.EncodeQuery([]string{"你好", "你好，世界！"})
+	_, _ = de.EncodePara([]string{"这是一段较长的文本。"}, []string{"", ""}) // Mismatched titles
+	_, _ = ce.Rank([]string{"你好", "你好"}, []string{"世界", "这是一段较长的文本。"}, nil)
+	_, _ = ce.Rerank(context.Background(), "你好", []rocketqa.Candidate{{Para: "世界"}}, nil)
+
+	type result struct {
+		Op        rocketqa.Op
+		BatchSize int
+		HasErr    bool
+	}
+	var got []result
+	for _, s := range hooks.stats {
+		got = append(got, result{Op: s.Op, BatchSize: s.BatchSize, HasErr: s.Err != nil})
+
+		if s.Err != nil {
+			continue
+		}
+		if s.Tokens <= 0 || s.Tokens > s.PaddedTokens {
+			t.Errorf("%s: got %d tokens and %d padded tokens", s.Op, s.Tokens, s.PaddedTokens)
+		}
+		if sum := s.Tokenize + s.Pad + s.QueueWait + s.Inference; s.Inference <= 0 || sum > s.Duration {
+			t.Errorf("%s: got invalid durations: %+v", s.Op, s)
+		}
+	}
+
+	want := []result{
+		{Op: rocketqa.OpEncodeQuery, BatchSize: 2},
+		{Op: rocketqa.OpEncodePara, BatchSize: 1, HasErr: true},
+		{Op: rocketqa.OpRank, BatchSize: 2},
+		{Op: rocketqa.OpRank, BatchSize: 1},
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// The tokens of the DE include both the queries and the placeholder
+	// paras, which are "[CLS] - [SEP] - [SEP]".
+	if s := hooks.stats[0]; s.Tokens != 4+8+2*5 || s.PaddedTokens != 2*8+2*5 {
+		t.Errorf("EncodeQuery: got %d tokens and %d padded tokens", s.Tokens, s.PaddedTokens)
+	}
+}
@@ -41,6 +41,8 @@ type Record struct {
 	TokenIDs    []int64
 	TextTypeIDs []int64
 	PositionIDs []int64
+	// The number of tokens dropped to fit the maximum sequence length.
+	Truncated int
 }
 
 type GeneratorConfig struct {
@@ -50,6 +52,8 @@ type GeneratorConfig struct {
 	ParaMaxSeqLength  int
 	MaxSeqLength      int
 	ForCN             bool
+	// The title of the paragraphs with empty titles. Defaults to none.
+	TitlePlaceholder string
 }
 
 type Generator struct {
@@ -58,6 +62,7 @@ type Generator struct {
 	paraMaxSeqLength  int
 	maxSeqLength      int
 	forCN             bool
+	titlePlaceholder  string
 }
 
 func NewGenerator(config GeneratorConfig) (*Generator, error) {
@@ -71,6 +76,7 @@ func NewGenerator(config GeneratorConfig) (*Generator, error) {
 		paraMaxSeqLength:  config.ParaMaxSeqLength,
 		maxSeqLength:      config.MaxSeqLength,
 		forCN:             config.ForCN,
+		titlePlaceholder:  config.TitlePlaceholder,
 	}, nil
 }
 
@@ -83,7 +89,7 @@ func (g *Generator) GenerateDE(e *Example) Data {
 	queryTokens := g.tokenizer.Tokenize(e.Query)
 	queryRecord := g.generate(queryTokens, nil, g.queryMaxSeqLength)
 
-	titleTokens := g.tokenizer.Tokenize(e.Title)
+	titleTokens := g.tokenizer.Tokenize(g.title(e.Title))
 	paraTokens := g.tokenizer.Tokenize(e.Para)
 	paraRecord := g.generate(titleTokens, paraTokens, g.paraMaxSeqLength)
 
@@ -98,12 +104,47 @@ func (g *Generator) GenerateCE(e *Example) Record {
 
 	tokensA := g.tokenizer.Tokenize(e.Query)
 
-	tokensB := g.tokenizer.Tokenize(e.Title)
+	tokensB := g.tokenizer.Tokenize(g.title(e.Title))
 	tokensB = append(tokensB, g.tokenizer.Tokenize(e.Para)...)
 
 	return g.generate(tokensA, tokensB, g.maxSeqLength)
 }
 
+// QueryIDs tokenizes the query into token IDs for cross encoder, which can
+// be reused across multiple calls of GenerateCEFromIDs.
+func (g *Generator) QueryIDs(query string) []int64 {
+	if g.forCN {
+		query = removeAllSpaces(query)
+	}
+	return g.tokenizer.TokensToIDs(g.tokenizer.Tokenize(query))
+}
+
+// ParaIDs tokenizes the title and the paragraph into token IDs for cross
+// encoder, which can be reused across multiple calls of GenerateCEFromIDs.
+func (g *Generator) ParaIDs(para, title string) []int64 {
+	if g.forCN {
+		title, para = removeAllSpaces(title), removeAllSpaces(para)
+	}
+	tokens := g.tokenizer.Tokenize(g.title(title))
+	tokens = append(tokens, g.tokenizer.Tokenize(para)...)
+	return g.tokenizer.TokensToIDs(tokens)
+}
+
+// GenerateCEFromIDs generates data for cross encoder from the token IDs
+// returned by QueryIDs and ParaIDs. The given slices are not modified.
+func (g *Generator) GenerateCEFromIDs(queryIDs, paraIDs []int64) Record {
+	return g.generateFromIDs(queryIDs, paraIDs, g.maxSeqLength)
+}
+
+// title returns the title to tokenize, which is the placeholder if title is
+// empty.
+func (g *Generator) title(title string) string {
+	if title == "" {
+		return g.titlePlaceholder
+	}
+	return title
+}
+
 // Pad pads the instances to the max sequence length in batch, and generate
 // the corresponding input mask, which is used to avoid attention on paddings.
 func (g *Generator) Pad(insts [][]int64) (padded [][]int64, inputMask [][]float32) {
@@ -165,36 +206,46 @@ func (g *Generator) Pad(insts [][]int64) (padded [][]int64, inputMask [][]float3
 // used as the "sentence vector". Note that this only makes sense because
 // the entire model is fine-tuned.
 func (g *Generator) generate(tokensA, tokensB []string, maxSeqLength int) Record {
-	padTokens := []string{"[CLS]", "[SEP]"}
-	if len(tokensB) > 0 {
-		padTokens = append(padTokens, "[SEP]")
+	idsA := g.tokenizer.TokensToIDs(tokensA)
+	idsB := g.tokenizer.TokensToIDs(tokensB)
+	return g.generateFromIDs(idsA, idsB, maxSeqLength)
+}
+
+// generateFromIDs is the same as generate, except that it accepts token IDs
+// instead of tokens.
+func (g *Generator) generateFromIDs(idsA, idsB []int64, maxSeqLength int) Record {
+	clsID, sepID := g.tokenizer.vocab["[CLS]"], g.tokenizer.vocab["[SEP]"]
+
+	numPads := 2
+	if len(idsB) > 0 {
+		numPads = 3
 	}
-	tokensA, tokensB = g.truncateSeqPair(tokensA, tokensB, maxSeqLength-len(padTokens))
+	n := len(idsA) + len(idsB)
+	idsA, idsB = truncateSeqPair(idsA, idsB, maxSeqLength-numPads)
+	truncated := n - len(idsA) - len(idsB)
 
-	var tokens []string
+	var ids []int64
 	var textTypeIDs []int64
 
-	tokens = append(tokens, padTokens[0])
+	ids = append(ids, clsID)
 	textTypeIDs = append(textTypeIDs, 0)
 
-	for _, token := range tokensA {
-		tokens = append(tokens, token)
+	for _, id := range idsA {
+		ids = append(ids, id)
 		textTypeIDs = append(textTypeIDs, 0)
 	}
-	tokens = append(tokens, padTokens[1])
+	ids = append(ids, sepID)
 	textTypeIDs = append(textTypeIDs, 0)
 
-	if len(tokensB) > 0 {
-		for _, token := range tokensB {
-			tokens = append(tokens, token)
+	if len(idsB) > 0 {
+		for _, id := range idsB {
+			ids = append(ids, id)
 			textTypeIDs = append(textTypeIDs, 1)
 		}
-		tokens = append(tokens, padTokens[2])
+		ids = append(ids, sepID)
 		textTypeIDs = append(textTypeIDs, 1)
 	}
 
-	ids := g.tokenizer.TokensToIDs(tokens)
-
 	var positionIDs []int64
 	for i := 0; i < len(ids); i++ {
 		positionIDs = append(positionIDs, int64(i))
@@ -204,6 +255,7 @@ func (g *Generator) generate(tokensA, tokensB []string, maxSeqLength int) Record
 		TokenIDs:    ids,
 		TextTypeIDs: textTypeIDs,
 		PositionIDs: positionIDs,
+		Truncated:   truncated,
 	}
 }
 
@@ -213,7 +265,7 @@ func (g *Generator) generate(tokensA, tokensB []string, maxSeqLength int) Record
 // one token at a time. This makes more sense than truncating an equal percent
 // of tokens from each, since if one sequence is very short then each token
 // that's truncated likely contains more information than a longer sequence.
-func (g *Generator) truncateSeqPair(tokensA []string, tokensB []string, maxLen int) (a []string, b []string) {
+func truncateSeqPair[T any](tokensA []T, tokensB []T, maxLen int) (a []T, b []T) {
 	for {
 		aLen, bLen := len(tokensA), len(tokensB)
 		if (aLen + bLen) <= maxLen {
@@ -140,6 +140,52 @@ func TestGenerator_GenerateCE(t *testing.T) {
 	}
 }
 
+func TestGenerator_GenerateCEFromIDs(t *testing.T) {
+	g, err := internal.NewGenerator(internal.GeneratorConfig{
+		VocabFile:    "../testdata/zh_vocab.txt",
+		DoLowerCase:  true,
+		MaxSeqLength: 16,
+		ForCN:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := "你好， 世界！"
+	queryIDs := g.QueryIDs(query)
+
+	tests := []struct {
+		inPara, inTitle string
+		wantTruncated   bool
+	}{
+		{inPara: "这是一段较长的文本。", wantTruncated: true},
+		{inPara: "这是 一段文本。", inTitle: "标题", wantTruncated: true},
+		{inPara: "This is a long paragraph, which will be truncated.", inTitle: "Title", wantTruncated: true},
+		{inPara: "文本。"},
+		{},
+	}
+	for _, tt := range tests {
+		// The same query IDs are reused across all the paragraphs.
+		gotRecord := g.GenerateCEFromIDs(queryIDs, g.ParaIDs(tt.inPara, tt.inTitle))
+		wantRecord := g.GenerateCE(&internal.Example{
+			Query: query,
+			Title: tt.inTitle,
+			Para:  tt.inPara,
+		})
+		if !cmp.Equal(gotRecord, wantRecord) {
+			diff := cmp.Diff(gotRecord, wantRecord)
+			t.Errorf("Want - Got: %s", diff)
+		}
+		if gotTruncated := gotRecord.Truncated > 0; gotTruncated != tt.wantTruncated {
+			t.Errorf("Truncated: got %d tokens, want truncated %v", gotRecord.Truncated, tt.wantTruncated)
+		}
+	}
+
+	if diff := cmp.Diff(g.QueryIDs(query), queryIDs); diff != "" {
+		t.Errorf("queryIDs modified, Want - Got: %s", diff)
+	}
+}
+
 func TestGenerator_Pad(t *testing.T) {
 	g, err := internal.NewGenerator(internal.GeneratorConfig{
 		VocabFile:    "../testdata/zh_vocab.txt",
@@ -183,3 +229,67 @@ func TestGenerator_Pad(t *testing.T) {
 		}
 	}
 }
+
+func TestGenerator_TitlePlaceholder(t *testing.T) {
+	newGenerator := func(placeholder string) *internal.Generator {
+		g, err := internal.NewGenerator(internal.GeneratorConfig{
+			VocabFile:         "../testdata/zh_vocab.txt",
+			DoLowerCase:       true,
+			QueryMaxSeqLength: 32,
+			ParaMaxSeqLength:  384,
+			MaxSeqLength:      384,
+			ForCN:             true,
+			TitlePlaceholder:  placeholder,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	g := newGenerator("-")
+	titled := newGenerator("")
+
+	para := "这是一段较长的文本。"
+
+	// An empty title is replaced by the placeholder.
+	wantDE := titled.GenerateDE(internal.NewExampleFromPara(para, "-"))
+	gotDE := g.GenerateDE(internal.NewExampleFromPara(para, ""))
+	if !cmp.Equal(gotDE, wantDE) {
+		diff := cmp.Diff(wantDE, gotDE)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	wantCE := titled.GenerateCE(&internal.Example{Query: "你好", Title: "-", Para: para})
+	gotCE := g.GenerateCE(&internal.Example{Query: "你好", Para: para})
+	if !cmp.Equal(gotCE, wantCE) {
+		diff := cmp.Diff(wantCE, gotCE)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	wantIDs := titled.ParaIDs(para, "-")
+	gotIDs := g.ParaIDs(para, "")
+	if !cmp.Equal(gotIDs, wantIDs) {
+		diff := cmp.Diff(wantIDs, gotIDs)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// A title of only spaces is cleaned, and then replaced by the
+	// placeholder, the same as in GenerateDE and GenerateCE.
+	wantIDs = g.ParaIDs(para, "")
+	gotIDs = g.ParaIDs(para, "  ")
+	if !cmp.Equal(gotIDs, wantIDs) {
+		diff := cmp.Diff(wantIDs, gotIDs)
+		t.Errorf("Want - Got: %s", diff)
+	}
+	wantCE = g.GenerateCE(&internal.Example{Query: "你好", Para: para})
+	if got := g.GenerateCEFromIDs(g.QueryIDs("你好"), gotIDs); !cmp.Equal(got, wantCE) {
+		diff := cmp.Diff(wantCE, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// A non-empty title is kept.
+	if got, want := g.ParaIDs(para, "标题"), titled.ParaIDs(para, "标题"); !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
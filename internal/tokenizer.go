@@ -2,6 +2,7 @@ package internal
 
 import (
 	"bufio"
+	"fmt"
 	"os"
 	"strings"
 	"unicode"
@@ -161,8 +162,17 @@ func newVocab(filename string) (vocab, error) {
 	for i := 0; scanner.Scan(); i++ {
 		v[scanner.Text()] = int64(i)
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-	return v, scanner.Err()
+	// The special tokens are required for generating the model inputs.
+	for _, token := range []string{"[PAD]", "[CLS]", "[SEP]", "[UNK]"} {
+		if _, ok := v[token]; !ok {
+			return nil, fmt.Errorf("%s: missing token %s", filename, token)
+		}
+	}
+	return v, nil
 }
 
 func (v vocab) TokensToIDs(tokens []string) (ids []int64) {
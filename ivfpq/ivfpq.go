@@ -0,0 +1,365 @@
+// Package ivfpq implements an IVF-PQ index for approximate nearest neighbor
+// search over the embeddings produced by the encoders.
+//
+// The vector space is first partitioned by a coarse quantizer (IVF) into
+// inverted lists, and the residual of every vector relative to its list
+// centroid is then compressed by a product quantizer (PQ) into one byte per
+// subspace. Searches only visit the NProbe lists closest to the query, and
+// compare the query with the compressed vectors by asymmetric distance
+// computation, using per-query lookup tables.
+package ivfpq
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/go-aie/rocketqa/fingerprint"
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+// Metric is the similarity measure used by the index.
+type Metric int
+
+const (
+	// InnerProduct ranks vectors by dot product, which equals cosine
+	// similarity for normalized vectors.
+	InnerProduct Metric = iota
+	// L2 ranks vectors by Euclidean distance.
+	L2
+)
+
+type Config struct {
+	// The number of inverted lists (coarse centroids).
+	NList int
+	// The number of subspaces of the product quantizer, which is also the
+	// number of bytes per encoded vector. The vector dimension must be a
+	// multiple of M.
+	M int
+	// The number of centroids per subspace. Defaults to 256, which is
+	// also the maximum.
+	KSub int
+	// The number of inverted lists to visit per search. Defaults to 1.
+	NProbe int
+	// The number of k-means iterations in training. Defaults to 25.
+	Iterations int
+	// The seed for training. Training is deterministic for the same seed
+	// and the same samples.
+	Seed int64
+	// The similarity measure. Defaults to InnerProduct.
+	Metric Metric
+	// The fingerprint of the encoder that produces the vectors (see
+	// rocketqa.DualEncoder.Fingerprint), which is saved along with the
+	// index. A loaded index with a fingerprint refuses Add and Search until
+	// CheckFingerprint confirms the fingerprint of the encoder in use.
+	// Optional.
+	Fingerprint string
+}
+
+// ErrFingerprintMismatch is returned if the index was built from the vectors
+// of another encoder, or its fingerprint is not checked. It is the same error
+// as rocketqa.ErrFingerprintMismatch.
+var ErrFingerprintMismatch = fingerprint.ErrMismatch
+
+// Hit is a search result. A higher Score means a closer match; for the L2
+// metric, Score is the negative squared distance.
+type Hit struct {
+	ID    int64
+	Score float32
+}
+
+type Index struct {
+	mu sync.RWMutex
+
+	dim    int
+	m      int
+	ksub   int
+	nprobe int
+	metric Metric
+
+	fingerprint string
+	// checked tells whether the vectors passed to Add and Search are known
+	// to come from the encoder with the fingerprint.
+	checked bool
+
+	// coarse holds the centroids of the inverted lists.
+	coarse [][]float32
+	// codebooks[i][j] is the j-th centroid of the i-th subspace.
+	codebooks [][][]float32
+	lists     []invertedList
+}
+
+type invertedList struct {
+	IDs []int64
+	// Codes holds M bytes per vector, in the same order as IDs.
+	Codes []byte
+}
+
+// Train creates an empty index whose quantizers are trained on the given
+// sample of vectors, typically a subset of the EncodePara outputs.
+func Train[V ~[]float32](cfg Config, samples []V) (*Index, error) {
+	if cfg.KSub == 0 {
+		cfg.KSub = 256
+	}
+	if cfg.NProbe <= 0 {
+		cfg.NProbe = 1
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = 25
+	}
+
+	switch {
+	case len(samples) == 0:
+		return nil, errors.New("no samples to train on")
+	case cfg.NList <= 0:
+		return nil, errors.New("NList must be positive")
+	case cfg.M <= 0:
+		return nil, errors.New("M must be positive")
+	case cfg.KSub < 1 || cfg.KSub > 256:
+		return nil, errors.New("KSub must be in [1, 256]")
+	case len(samples) < cfg.NList || len(samples) < cfg.KSub:
+		return nil, fmt.Errorf("got %d samples, want at least max(NList, KSub)", len(samples))
+	}
+
+	dim := len(samples[0])
+	if dim%cfg.M != 0 {
+		return nil, fmt.Errorf("dimension %d is not a multiple of M (%d)", dim, cfg.M)
+	}
+
+	data := make([][]float32, len(samples))
+	for i, s := range samples {
+		if len(s) != dim {
+			return nil, errors.New("samples have different dimensions")
+		}
+		data[i] = s
+	}
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+	coarse := kmeans(data, cfg.NList, cfg.Iterations, r)
+
+	// Train the product quantizer on the residuals.
+	dsub := dim / cfg.M
+	subData := make([][][]float32, cfg.M)
+	for _, v := range data {
+		residual := sub(v, coarse[nearest(coarse, v)])
+		for i := range subData {
+			subData[i] = append(subData[i], residual[i*dsub:(i+1)*dsub])
+		}
+	}
+	codebooks := make([][][]float32, cfg.M)
+	for i := range codebooks {
+		codebooks[i] = kmeans(subData[i], cfg.KSub, cfg.Iterations, r)
+	}
+
+	return &Index{
+		dim:       dim,
+		m:         cfg.M,
+		ksub:      cfg.KSub,
+		nprobe:    cfg.NProbe,
+		metric:    cfg.Metric,
+		coarse:    coarse,
+		codebooks: codebooks,
+		lists:     make([]invertedList, cfg.NList),
+
+		fingerprint: cfg.Fingerprint,
+		checked:     true,
+	}, nil
+}
+
+// Dim returns the dimension of the vectors in the index.
+func (idx *Index) Dim() int {
+	return idx.dim
+}
+
+// Fingerprint returns the fingerprint of the encoder that produces the
+// vectors, or an empty string if unknown.
+func (idx *Index) Fingerprint() string {
+	return idx.fingerprint
+}
+
+// CheckFingerprint returns an error wrapping ErrFingerprintMismatch if the
+// index was built from the vectors of an encoder other than the one with the
+// given fingerprint. Indexes with unknown fingerprints are assumed to match.
+//
+// Add and Search only accept vectors if the last check succeeded, or the
+// index was trained (rather than loaded) with its fingerprint.
+func (idx *Index) CheckFingerprint(fingerprint string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.checked = idx.fingerprint == "" || idx.fingerprint == fingerprint
+	if !idx.checked {
+		return fmt.Errorf("%w: index has %q, encoder has %q", ErrFingerprintMismatch, idx.fingerprint, fingerprint)
+	}
+	return nil
+}
+
+// checkVectors returns an error wrapping ErrFingerprintMismatch if the
+// vectors passed to Add and Search are not known to come from the encoder
+// with the fingerprint of the index. It must be called with idx.mu held.
+func (idx *Index) checkVectors() error {
+	if !idx.checked {
+		return fmt.Errorf("%w: index has %q, which is not checked (see CheckFingerprint)", ErrFingerprintMismatch, idx.fingerprint)
+	}
+	return nil
+}
+
+// Len returns the number of vectors in the index.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var n int
+	for _, l := range idx.lists {
+		n += len(l.IDs)
+	}
+	return n
+}
+
+// SetNProbe sets the number of inverted lists to visit per search. A larger
+// value gives a higher recall at the cost of speed.
+func (idx *Index) SetNProbe(nprobe int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if nprobe < 1 {
+		nprobe = 1
+	}
+	idx.nprobe = nprobe
+}
+
+// Add encodes the vector v and adds it to the index under the given ID.
+func (idx *Index) Add(id int64, v []float32) error {
+	if len(v) != idx.dim {
+		return fmt.Errorf("got dimension %d, want %d", len(v), idx.dim)
+	}
+
+	list := nearest(idx.coarse, v)
+	code := idx.encode(sub(v, idx.coarse[list]))
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.checkVectors(); err != nil {
+		return err
+	}
+	l := &idx.lists[list]
+	l.IDs = append(l.IDs, id)
+	l.Codes = append(l.Codes, code...)
+	return nil
+}
+
+// AddBatch adds the vectors vs under the corresponding IDs.
+func AddBatch[V ~[]float32](idx *Index, ids []int64, vs []V) error {
+	if len(ids) != len(vs) {
+		return errors.New("len(ids) does not equal len(vs)")
+	}
+	for i, v := range vs {
+		if err := idx.Add(ids[i], v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search returns the k approximate nearest neighbors of query, best first.
+func (idx *Index) Search(query []float32, k int) ([]Hit, error) {
+	if len(query) != idx.dim {
+		return nil, fmt.Errorf("got dimension %d, want %d", len(query), idx.dim)
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if err := idx.checkVectors(); err != nil {
+		return nil, err
+	}
+
+	// Select the lists to probe.
+	coarseScores := make([]float32, len(idx.coarse))
+	for i, c := range idx.coarse {
+		coarseScores[i] = -vecmath.SquaredL2(query, c)
+	}
+	probes := vecmath.TopK(coarseScores, idx.nprobe)
+
+	var ids []int64
+	var scores []float32
+
+	dsub := idx.dim / idx.m
+	lut := make([]float32, idx.m*idx.ksub)
+	if idx.metric == InnerProduct {
+		// <q, c + r> = <q, c> + <q, r>, where the lookup table of <q, r>
+		// does not depend on the list since the codebooks are shared.
+		idx.fillIPTable(lut, query, dsub)
+	}
+
+	for _, p := range probes {
+		l := idx.lists[p.Index]
+		if len(l.IDs) == 0 {
+			continue
+		}
+
+		var base float32
+		switch idx.metric {
+		case InnerProduct:
+			base = vecmath.Dot(query, idx.coarse[p.Index])
+		case L2:
+			idx.fillL2Table(lut, sub(query, idx.coarse[p.Index]), dsub)
+		}
+
+		for i, id := range l.IDs {
+			code := l.Codes[i*idx.m : (i+1)*idx.m]
+			var s float32
+			for j, c := range code {
+				s += lut[j*idx.ksub+int(c)]
+			}
+			if idx.metric == L2 {
+				s = -s
+			}
+			ids = append(ids, id)
+			scores = append(scores, base+s)
+		}
+	}
+
+	var hits []Hit
+	for _, m := range vecmath.TopK(scores, k) {
+		hits = append(hits, Hit{ID: ids[m.Index], Score: m.Score})
+	}
+	return hits, nil
+}
+
+func (idx *Index) encode(residual []float32) []byte {
+	dsub := idx.dim / idx.m
+	code := make([]byte, idx.m)
+	for i, cb := range idx.codebooks {
+		code[i] = byte(nearest(cb, residual[i*dsub:(i+1)*dsub]))
+	}
+	return code
+}
+
+func (idx *Index) fillIPTable(lut, query []float32, dsub int) {
+	for i, cb := range idx.codebooks {
+		q := query[i*dsub : (i+1)*dsub]
+		for j, c := range cb {
+			lut[i*idx.ksub+j] = vecmath.Dot(q, c)
+		}
+	}
+}
+
+func (idx *Index) fillL2Table(lut, residual []float32, dsub int) {
+	for i, cb := range idx.codebooks {
+		r := residual[i*dsub : (i+1)*dsub]
+		for j, c := range cb {
+			lut[i*idx.ksub+j] = vecmath.SquaredL2(r, c)
+		}
+	}
+}
+
+func sub(a, b []float32) []float32 {
+	result := make([]float32, len(a))
+	for i := range a {
+		result[i] = a[i] - b[i]
+	}
+	return result
+}
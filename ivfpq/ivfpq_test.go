@@ -0,0 +1,355 @@
+package ivfpq_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/ivfpq"
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+type Vector []float32
+
+func TestIndex_Search(t *testing.T) {
+	corpus, queries := syntheticData(1, 2000, 64, 32)
+
+	tests := []struct {
+		name       string
+		inMetric   ivfpq.Metric
+		inNProbe   int
+		wantRecall float64
+	}{
+		{"IP-1", ivfpq.InnerProduct, 1, 0.5},
+		{"IP-4", ivfpq.InnerProduct, 4, 0.5},
+		{"IP-16", ivfpq.InnerProduct, 16, 0.5},
+		{"L2-4", ivfpq.L2, 4, 0.7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := newIndex(t, corpus, tt.inMetric, 1)
+			idx.SetNProbe(tt.inNProbe)
+
+			gotRecall := measureRecall(t, idx, tt.inMetric, corpus, queries, 10)
+			t.Logf("1-recall@10 = %.3f", gotRecall)
+			if gotRecall < tt.wantRecall {
+				t.Errorf("Got recall (%.3f) < Want (%.3f)", gotRecall, tt.wantRecall)
+			}
+		})
+	}
+}
+
+func TestTrain_Deterministic(t *testing.T) {
+	corpus, _ := syntheticData(1, 500, 10, 16)
+
+	save := func(seed int64) []byte {
+		var buf bytes.Buffer
+		if err := newIndex(t, corpus, ivfpq.InnerProduct, seed).Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	if !bytes.Equal(save(42), save(42)) {
+		t.Errorf("Want identical indexes for the same seed")
+	}
+	if bytes.Equal(save(42), save(43)) {
+		t.Errorf("Want different indexes for different seeds")
+	}
+}
+
+func TestIndex_SaveLoad(t *testing.T) {
+	corpus, queries := syntheticData(1, 500, 10, 16)
+	idx := newIndex(t, corpus, ivfpq.InnerProduct, 1)
+	idx.SetNProbe(3)
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ivfpq.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != idx.Len() {
+		t.Errorf("Len: Got (%v) != Want (%v)", loaded.Len(), idx.Len())
+	}
+	for _, q := range queries {
+		want, _ := idx.Search(q, 5)
+		got, _ := loaded.Search(q, 5)
+		if !cmp.Equal(got, want) {
+			diff := cmp.Diff(got, want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	corpus, _ := syntheticData(1, 100, 5, 16)
+	idx := newIndex(t, corpus, ivfpq.InnerProduct, 1)
+
+	tests := []struct {
+		name    string
+		corrupt func(d *savedIndex)
+	}{
+		{
+			name:    "unknown version",
+			corrupt: func(d *savedIndex) { d.Version++ },
+		},
+		{
+			name:    "dimension not a multiple of M",
+			corrupt: func(d *savedIndex) { d.Dim++ },
+		},
+		{
+			name:    "zero M",
+			corrupt: func(d *savedIndex) { d.M = 0 },
+		},
+		{
+			name:    "missing inverted list",
+			corrupt: func(d *savedIndex) { d.Lists = d.Lists[1:] },
+		},
+		{
+			name:    "missing codebook",
+			corrupt: func(d *savedIndex) { d.Codebooks = d.Codebooks[1:] },
+		},
+		{
+			name:    "short coarse centroid",
+			corrupt: func(d *savedIndex) { d.Coarse[0] = d.Coarse[0][1:] },
+		},
+		{
+			name:    "truncated codes",
+			corrupt: func(d *savedIndex) { l := d.nonEmptyList(); l.Codes = l.Codes[1:] },
+		},
+		{
+			name: "code out of range",
+			corrupt: func(d *savedIndex) {
+				d.KSub = 1
+				d.Codebooks = nil
+				for i := 0; i < d.M; i++ {
+					d.Codebooks = append(d.Codebooks, [][]float32{make([]float32, d.Dim/d.M)})
+				}
+				d.nonEmptyList().Codes[0] = 1
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := idx.Save(&buf); err != nil {
+				t.Fatal(err)
+			}
+			var d savedIndex
+			if err := gob.NewDecoder(&buf).Decode(&d); err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(&d)
+			if err := gob.NewEncoder(&buf).Encode(d); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := ivfpq.Load(&buf); err == nil {
+				t.Errorf("Want error for an invalid index")
+			}
+		})
+	}
+}
+
+// savedIndex mirrors the fields of the saved index, which gob matches by
+// name.
+type savedIndex struct {
+	Version     int
+	Dim         int
+	M           int
+	KSub        int
+	NProbe      int
+	Metric      ivfpq.Metric
+	Coarse      [][]float32
+	Codebooks   [][][]float32
+	Lists       []savedList
+	Fingerprint string
+}
+
+type savedList struct {
+	IDs   []int64
+	Codes []byte
+}
+
+func (d *savedIndex) nonEmptyList() *savedList {
+	for i := range d.Lists {
+		if len(d.Lists[i].IDs) > 0 {
+			return &d.Lists[i]
+		}
+	}
+	panic("no vectors in the index")
+}
+
+func TestIndex_CheckFingerprint(t *testing.T) {
+	corpus, _ := syntheticData(1, 100, 5, 16)
+	idx, err := ivfpq.Train(ivfpq.Config{NList: 4, M: 4, KSub: 16, Fingerprint: "abc"}, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := ivfpq.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loaded.Fingerprint(); got != "abc" {
+		t.Errorf("Fingerprint: Got (%v) != Want (abc)", got)
+	}
+
+	// The loaded index refuses vectors until its fingerprint is checked.
+	if _, err := loaded.Search(corpus[0], 1); !errors.Is(err, ivfpq.ErrFingerprintMismatch) {
+		t.Errorf("Search: Want ErrFingerprintMismatch, got %v", err)
+	}
+	if err := loaded.CheckFingerprint("abc"); err != nil {
+		t.Errorf("Want no error, got %v", err)
+	}
+	if _, err := loaded.Search(corpus[0], 1); err != nil {
+		t.Errorf("Search: Want no error, got %v", err)
+	}
+
+	if err := loaded.CheckFingerprint("def"); !errors.Is(err, ivfpq.ErrFingerprintMismatch) {
+		t.Errorf("Want ErrFingerprintMismatch, got %v", err)
+	}
+	if err := loaded.Add(100, corpus[0]); !errors.Is(err, ivfpq.ErrFingerprintMismatch) {
+		t.Errorf("Add: Want ErrFingerprintMismatch, got %v", err)
+	}
+}
+
+func TestTrain_Error(t *testing.T) {
+	corpus, _ := syntheticData(1, 100, 5, 16)
+
+	tests := []struct {
+		name     string
+		inConfig ivfpq.Config
+		inData   []Vector
+	}{
+		{"no samples", ivfpq.Config{NList: 4, M: 4}, nil},
+		{"too few samples", ivfpq.Config{NList: 4, M: 4, KSub: 256}, corpus},
+		{"bad M", ivfpq.Config{NList: 4, M: 5, KSub: 16}, corpus},
+		{"bad NList", ivfpq.Config{NList: 0, M: 4, KSub: 16}, corpus},
+	}
+	for _, tt := range tests {
+		if _, err := ivfpq.Train(tt.inConfig, tt.inData); err == nil {
+			t.Errorf("%s: Want error", tt.name)
+		}
+	}
+
+	idx := newIndex(t, corpus, ivfpq.InnerProduct, 1)
+	if err := idx.Add(0, make([]float32, 8)); err == nil {
+		t.Errorf("Want error for mismatched dimension")
+	}
+}
+
+func BenchmarkIndex_Search(b *testing.B) {
+	corpus, queries := syntheticData(1, 10000, 50, 128)
+	idx, err := ivfpq.Train(ivfpq.Config{NList: 64, M: 16, Seed: 1}, corpus[:4000])
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i, v := range corpus {
+		_ = idx.Add(int64(i), v)
+	}
+	idx.SetNProbe(8)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = idx.Search(queries[i%len(queries)], 10)
+	}
+}
+
+func newIndex(t *testing.T, corpus []Vector, metric ivfpq.Metric, seed int64) *ivfpq.Index {
+	idx, err := ivfpq.Train(ivfpq.Config{
+		NList:  16,
+		M:      8,
+		KSub:   64,
+		Seed:   seed,
+		Metric: metric,
+	}, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := make([]int64, len(corpus))
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+	if err := ivfpq.AddBatch(idx, ids, corpus); err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+// measureRecall returns the 1-recall@k, which is the fraction of queries
+// whose exact nearest neighbor is among the top k hits.
+func measureRecall(t *testing.T, idx *ivfpq.Index, metric ivfpq.Metric, corpus, queries []Vector, k int) float64 {
+	var total float64
+	for _, q := range queries {
+		scores := make([]float32, len(corpus))
+		for i, v := range corpus {
+			if metric == ivfpq.L2 {
+				scores[i] = -vecmath.SquaredL2(q, v)
+			} else {
+				scores[i] = vecmath.Dot(q, v)
+			}
+		}
+		want := int64(vecmath.TopK(scores, 1)[0].Index)
+
+		hits, err := idx.Search(q, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range hits {
+			if h.ID == want {
+				total++
+				break
+			}
+		}
+	}
+	return total / float64(len(queries))
+}
+
+// syntheticData generates normalized vectors gathered around random centers,
+// which mimics the clustered structure of real embeddings.
+func syntheticData(seed int64, n, clusters, dim int) (corpus, queries []Vector) {
+	r := rand.New(rand.NewSource(seed))
+
+	centers := make([]Vector, clusters)
+	for i := range centers {
+		centers[i] = randomVector(r, dim, 1)
+	}
+	gen := func() Vector {
+		c := centers[r.Intn(clusters)]
+		v := randomVector(r, dim, 0.3)
+		for i := range v {
+			v[i] += c[i]
+		}
+		return vecmath.Normalize(v)
+	}
+
+	for i := 0; i < n; i++ {
+		corpus = append(corpus, gen())
+	}
+	for i := 0; i < 100; i++ {
+		queries = append(queries, gen())
+	}
+	return
+}
+
+func randomVector(r *rand.Rand, dim int, stddev float64) Vector {
+	v := make(Vector, dim)
+	for i := range v {
+		v[i] = float32(r.NormFloat64() * stddev)
+	}
+	return v
+}
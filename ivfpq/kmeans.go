@@ -0,0 +1,80 @@
+package ivfpq
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+// kmeans clusters data into k centroids by using Lloyd's algorithm, with
+// the initial centroids sampled from data by r. The result is deterministic
+// for the same data and the same state of r.
+func kmeans(data [][]float32, k, iterations int, r *rand.Rand) [][]float32 {
+	dim := len(data[0])
+
+	centroids := make([][]float32, k)
+	for i, j := range r.Perm(len(data))[:k] {
+		centroids[i] = append([]float32(nil), data[j]...)
+	}
+
+	assign := make([]int, len(data))
+	sums := make([][]float64, k)
+	for i := range sums {
+		sums[i] = make([]float64, dim)
+	}
+	counts := make([]int, k)
+
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, v := range data {
+			c := nearest(centroids, v)
+			if c != assign[i] || iter == 0 {
+				changed = true
+			}
+			assign[i] = c
+		}
+		if !changed {
+			break
+		}
+
+		for i := range sums {
+			for j := range sums[i] {
+				sums[i][j] = 0
+			}
+			counts[i] = 0
+		}
+		for i, v := range data {
+			c := assign[i]
+			counts[c]++
+			for j, x := range v {
+				sums[c][j] += float64(x)
+			}
+		}
+
+		for i, centroid := range centroids {
+			if counts[i] == 0 {
+				// Re-seed an empty cluster with a random point, which
+				// is a simple way to keep all the k centroids in use.
+				copy(centroid, data[r.Intn(len(data))])
+				continue
+			}
+			for j := range centroid {
+				centroid[j] = float32(sums[i][j] / float64(counts[i]))
+			}
+		}
+	}
+
+	return centroids
+}
+
+// nearest returns the index of the centroid closest to v in L2 distance.
+func nearest(centroids [][]float32, v []float32) int {
+	best, bestDist := 0, float32(math.Inf(1))
+	for i, c := range centroids {
+		if d := vecmath.SquaredL2(c, v); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
@@ -0,0 +1,128 @@
+package ivfpq
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// formatVersion is bumped whenever the layout of indexData changes.
+const formatVersion = 1
+
+// indexData is the serialized form of Index.
+type indexData struct {
+	Version   int
+	Dim       int
+	M         int
+	KSub      int
+	NProbe    int
+	Metric    Metric
+	Coarse    [][]float32
+	Codebooks [][][]float32
+	Lists     []invertedList
+
+	Fingerprint string
+}
+
+// Save writes the index, including all the added vectors, to w.
+func (idx *Index) Save(w io.Writer) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return gob.NewEncoder(w).Encode(indexData{
+		Version:   formatVersion,
+		Dim:       idx.dim,
+		M:         idx.m,
+		KSub:      idx.ksub,
+		NProbe:    idx.nprobe,
+		Metric:    idx.metric,
+		Coarse:    idx.coarse,
+		Codebooks: idx.codebooks,
+		Lists:     idx.lists,
+
+		Fingerprint: idx.fingerprint,
+	})
+}
+
+// Load reads an index previously written by Save from r.
+func Load(r io.Reader) (*Index, error) {
+	var data indexData
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+	if data.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported index format version %d", data.Version)
+	}
+	if err := data.validate(); err != nil {
+		return nil, fmt.Errorf("invalid index: %w", err)
+	}
+
+	return &Index{
+		dim:       data.Dim,
+		m:         data.M,
+		ksub:      data.KSub,
+		nprobe:    data.NProbe,
+		metric:    data.Metric,
+		coarse:    data.Coarse,
+		codebooks: data.Codebooks,
+		lists:     data.Lists,
+
+		fingerprint: data.Fingerprint,
+		checked:     data.Fingerprint == "",
+	}, nil
+}
+
+// validate checks that the decoded structure is consistent, so that a
+// truncated or corrupt file fails to load, instead of panicking later in
+// Add or Search.
+func (d *indexData) validate() error {
+	switch {
+	case d.Dim <= 0:
+		return errors.New("dimension must be positive")
+	case d.M <= 0:
+		return errors.New("M must be positive")
+	case d.Dim%d.M != 0:
+		return fmt.Errorf("dimension %d is not a multiple of M (%d)", d.Dim, d.M)
+	case d.KSub < 1 || d.KSub > 256:
+		return errors.New("KSub must be in [1, 256]")
+	case d.NProbe <= 0:
+		return errors.New("NProbe must be positive")
+	case d.Metric != InnerProduct && d.Metric != L2:
+		return fmt.Errorf("unknown metric %d", d.Metric)
+	case len(d.Coarse) == 0:
+		return errors.New("no coarse centroids")
+	case len(d.Lists) != len(d.Coarse):
+		return fmt.Errorf("got %d inverted lists, want %d", len(d.Lists), len(d.Coarse))
+	case len(d.Codebooks) != d.M:
+		return fmt.Errorf("got %d codebooks, want %d", len(d.Codebooks), d.M)
+	}
+
+	for i, c := range d.Coarse {
+		if len(c) != d.Dim {
+			return fmt.Errorf("coarse centroid %d has dimension %d, want %d", i, len(c), d.Dim)
+		}
+	}
+	dsub := d.Dim / d.M
+	for i, codebook := range d.Codebooks {
+		if len(codebook) != d.KSub {
+			return fmt.Errorf("codebook %d has %d centroids, want %d", i, len(codebook), d.KSub)
+		}
+		for j, c := range codebook {
+			if len(c) != dsub {
+				return fmt.Errorf("centroid %d of codebook %d has dimension %d, want %d", j, i, len(c), dsub)
+			}
+		}
+	}
+	for i, l := range d.Lists {
+		if len(l.Codes) != d.M*len(l.IDs) {
+			return fmt.Errorf("inverted list %d has %d code bytes, want %d", i, len(l.Codes), d.M*len(l.IDs))
+		}
+		for _, code := range l.Codes {
+			if int(code) >= d.KSub {
+				return fmt.Errorf("inverted list %d has code %d, want less than %d", i, code, d.KSub)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package rocketqa
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID, which is
+// attached to the logs of the calls made with the returned context (see
+// LogHooks and the *Context methods of the encoders).
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "" if there is none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type LogHooksConfig struct {
+	// The logger. Defaults to slog.Default().
+	Logger *slog.Logger
+	// The calls taking at least this long are logged as slow, at the warning
+	// level. Zero disables the slow call logs.
+	SlowThreshold time.Duration
+}
+
+// LogHooks logs the noteworthy calls of the encoders:
+//
+//   - failed calls (including the panics of the inference engine), at the
+//     error level;
+//   - calls whose inputs were truncated to fit the maximum sequence length,
+//     at the warning level;
+//   - calls slower than LogHooksConfig.SlowThreshold, at the warning level.
+//
+// The logs carry the request ID of the context (see WithRequestID), if any,
+// and the context itself is passed to the slog handler.
+type LogHooks struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+func NewLogHooks(cfg *LogHooksConfig) *LogHooks {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogHooks{
+		logger:        logger,
+		slowThreshold: cfg.SlowThreshold,
+	}
+}
+
+func (h *LogHooks) CallStarted(ctx context.Context, op Op) context.Context {
+	return ctx
+}
+
+func (h *LogHooks) CallFinished(ctx context.Context, stats CallStats) {
+	attrs := []slog.Attr{
+		slog.String("op", string(stats.Op)),
+		slog.Int("batch_size", stats.BatchSize),
+	}
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+
+	if stats.Err != nil {
+		attrs = append(attrs, slog.Duration("duration", stats.Duration), slog.Any("error", stats.Err))
+		h.logger.LogAttrs(ctx, slog.LevelError, "rocketqa: call failed", attrs...)
+		return
+	}
+
+	if stats.Truncated > 0 {
+		h.logger.LogAttrs(ctx, slog.LevelWarn, "rocketqa: inputs truncated to the maximum sequence length",
+			append(attrs, slog.Int("truncated", stats.Truncated))...)
+	}
+
+	if h.slowThreshold > 0 && stats.Duration >= h.slowThreshold {
+		h.logger.LogAttrs(ctx, slog.LevelWarn, "rocketqa: slow call",
+			append(attrs,
+				slog.Duration("duration", stats.Duration),
+				slog.Duration("queue_wait", stats.QueueWait),
+				slog.Duration("inference", stats.Inference),
+				slog.Int("tokens", stats.Tokens),
+				slog.Int("padded_tokens", stats.PaddedTokens),
+			)...)
+	}
+}
+
+// MultiHooks returns hooks that forward the events to all the given hooks,
+// e.g. to both log and export metrics. Each of them gets back the context
+// returned by its own CallStarted.
+func MultiHooks(hooks ...Hooks) Hooks {
+	return multiHooks(hooks)
+}
+
+type multiHooks []Hooks
+
+type multiHooksKey struct{}
+
+func (m multiHooks) CallStarted(ctx context.Context, op Op) context.Context {
+	ctxs := make([]context.Context, len(m))
+	for i, h := range m {
+		ctx = h.CallStarted(ctx, op)
+		ctxs[i] = ctx
+	}
+	return context.WithValue(ctx, multiHooksKey{}, ctxs)
+}
+
+func (m multiHooks) CallFinished(ctx context.Context, stats CallStats) {
+	ctxs, _ := ctx.Value(multiHooksKey{}).([]context.Context)
+	// Finish in the reverse order, like nested spans.
+	for i := len(m) - 1; i >= 0; i-- {
+		hctx := ctx
+		if len(ctxs) == len(m) {
+			hctx = ctxs[i]
+		}
+		m[i].CallFinished(hctx, stats)
+	}
+}
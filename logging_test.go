@@ -0,0 +1,133 @@
+package rocketqa_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+// logRecord is the subset of a JSON log record checked by the tests.
+type logRecord struct {
+	Level     string
+	Msg       string
+	RequestID string `json:"request_id"`
+}
+
+func parseLogs(t *testing.T, buf *bytes.Buffer) []logRecord {
+	var records []logRecord
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r logRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestLogHooks(t *testing.T) {
+	ctx := rocketqa.WithRequestID(context.Background(), "req-1")
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		stats rocketqa.CallStats
+		want  []logRecord
+	}{
+		{
+			name:  "quiet",
+			ctx:   ctx,
+			stats: rocketqa.CallStats{Op: rocketqa.OpRank, Duration: time.Millisecond},
+		},
+		{
+			name:  "failed",
+			ctx:   ctx,
+			stats: rocketqa.CallStats{Op: rocketqa.OpRank, Duration: time.Second, Err: errors.New("panic: oops")},
+			want: []logRecord{
+				{Level: "ERROR", Msg: "rocketqa: call failed", RequestID: "req-1"},
+			},
+		},
+		{
+			name:  "truncated and slow",
+			ctx:   ctx,
+			stats: rocketqa.CallStats{Op: rocketqa.OpEncodePara, Truncated: 1, Duration: time.Second},
+			want: []logRecord{
+				{Level: "WARN", Msg: "rocketqa: inputs truncated to the maximum sequence length", RequestID: "req-1"},
+				{Level: "WARN", Msg: "rocketqa: slow call", RequestID: "req-1"},
+			},
+		},
+		{
+			name:  "no request ID",
+			ctx:   context.Background(),
+			stats: rocketqa.CallStats{Op: rocketqa.OpEncodeQuery, Duration: time.Second},
+			want: []logRecord{
+				{Level: "WARN", Msg: "rocketqa: slow call"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			hooks := rocketqa.NewLogHooks(&rocketqa.LogHooksConfig{
+				Logger:        slog.New(slog.NewJSONHandler(&buf, nil)),
+				SlowThreshold: 100 * time.Millisecond,
+			})
+
+			hooks.CallFinished(hooks.CallStarted(tt.ctx, tt.stats.Op), tt.stats)
+
+			got := parseLogs(t, &buf)
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(tt.want, got)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestMultiHooks(t *testing.T) {
+	first, second := new(recordingHooks), new(recordingHooks)
+	var buf bytes.Buffer
+	logHooks := rocketqa.NewLogHooks(&rocketqa.LogHooksConfig{
+		Logger: slog.New(slog.NewJSONHandler(&buf, nil)),
+	})
+
+	cfg := newCrossEncoderConfig(1)
+	cfg.MaxSeqLength = 8
+	cfg.Hooks = rocketqa.MultiHooks(first, logHooks, second)
+	ce, err := rocketqa.NewCrossEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := rocketqa.WithRequestID(context.Background(), "req-2")
+	if _, err := ce.RankContext(ctx, []string{"你好"}, []string{"这是一段较长的文本。"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []*recordingHooks{first, second} {
+		// The stats of Op would be replaced if the contexts were mixed up.
+		if len(h.stats) != 1 || h.stats[0].Op != rocketqa.OpRank || h.stats[0].Truncated != 1 {
+			t.Errorf("got stats %+v", h.stats)
+		}
+	}
+
+	want := []logRecord{
+		{Level: "WARN", Msg: "rocketqa: inputs truncated to the maximum sequence length", RequestID: "req-2"},
+	}
+	if got := parseLogs(t, &buf); !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
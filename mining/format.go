@@ -0,0 +1,57 @@
+package mining
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/go-aie/rocketqa/eval"
+)
+
+// placeholderTitle stands for empty titles in the training sets, as in the
+// examples of RocketQA.
+const placeholderTitle = "-"
+
+// WriteCross writes the examples in the training format of the cross encoder
+// ("query\ttitle\tpara\tlabel"), with one line per positive (labeled 1) and
+// one line per negative (labeled 0).
+func WriteCross(w io.Writer, examples []Example) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range examples {
+		for _, p := range e.Positives {
+			if err := eval.WriteFields(bw, e.Query, title(p.Title), p.Para, "1"); err != nil {
+				return err
+			}
+		}
+		for _, n := range e.Negatives {
+			if err := eval.WriteFields(bw, e.Query, title(n.Title), n.Para, "0"); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteDual writes the examples in the training format of the dual encoder
+// ("query\tpos_title\tpos_para\tneg_title\tneg_para\tlabel", where label is
+// always 0), with one line per negative. The negatives are paired with the
+// positives in turn.
+func WriteDual(w io.Writer, examples []Example) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range examples {
+		for i, n := range e.Negatives {
+			p := e.Positives[i%len(e.Positives)]
+			if err := eval.WriteFields(bw, e.Query, title(p.Title), p.Para, title(n.Title), n.Para, "0"); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func title(t string) string {
+	if strings.TrimSpace(t) == "" {
+		return placeholderTitle
+	}
+	return t
+}
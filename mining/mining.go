@@ -0,0 +1,175 @@
+// Package mining mines hard negatives for training the encoders: for every
+// labeled query, the top candidates retrieved from the corpus that are not
+// known positives, optionally dropping the likely false negatives scored
+// high by a cross encoder.
+package mining
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/retrieval"
+)
+
+type Config struct {
+	// The retriever of the candidates, such as a retrieval.DenseIndex over
+	// the corpus built with the dual encoder to be trained. Required.
+	Retriever retrieval.Retriever
+	// The number of candidates retrieved per query. Defaults to 50.
+	Depth int
+	// The maximum number of negatives kept per query, which are the best
+	// ranked candidates left after filtering. Defaults to 4.
+	Negatives int
+	// An optional ranker, such as *rocketqa.CrossEncoder, to filter out the
+	// false negatives, i.e. the unlabeled candidates that are actually
+	// relevant.
+	Ranker retrieval.Ranker
+	// If not nil, the candidates whose Ranker scores are at least *MaxScore
+	// are dropped, where any value is valid (e.g. negative for rankers that
+	// output logits). Only used if Ranker is set. Defaults to 0.9.
+	MaxScore *float32
+}
+
+// Example is a training example of one query.
+type Example struct {
+	Query     string
+	Positives []retrieval.Document
+	// The negatives, hardest (i.e. best ranked) first.
+	Negatives []retrieval.Document
+}
+
+// Stats holds the counters of a mining run.
+type Stats struct {
+	// The number of queries with examples.
+	Queries int
+	// The number of labeled queries skipped, because none of their positives
+	// are in the corpus, or no negatives are left.
+	Skipped int
+	// The number of candidates dropped because they are known positives.
+	Positives int
+	// The number of candidates dropped as false negatives by Ranker. The
+	// candidates beyond those needed are not scored, and not counted.
+	FalseNegatives int
+}
+
+// Mine mines the negatives of every query with positive judgments in qrels.
+// The positives are looked up in corpus by their IDs.
+func Mine(ctx context.Context, cfg *Config, corpus []retrieval.Document, queries []eval.Query, qrels eval.Qrels) ([]Example, Stats, error) {
+	var stats Stats
+	if cfg.Retriever == nil {
+		return nil, stats, errors.New("no retriever")
+	}
+	depth := cfg.Depth
+	if depth <= 0 {
+		depth = 50
+	}
+	numNegatives := cfg.Negatives
+	if numNegatives <= 0 {
+		numNegatives = 4
+	}
+	maxScore := float32(0.9)
+	if cfg.MaxScore != nil {
+		maxScore = *cfg.MaxScore
+	}
+
+	docs := make(map[string]retrieval.Document, len(corpus))
+	for _, d := range corpus {
+		docs[d.ID] = d
+	}
+
+	var examples []Example
+	for _, q := range queries {
+		grades := qrels[q.ID]
+		if qrels.Relevant(q.ID) == 0 {
+			continue
+		}
+
+		e := Example{Query: q.Text}
+		for id, grade := range grades {
+			if d, ok := docs[id]; ok && grade > 0 {
+				e.Positives = append(e.Positives, d)
+			}
+		}
+		if len(e.Positives) == 0 {
+			stats.Skipped++
+			continue
+		}
+		// Keep the output deterministic.
+		sort.Slice(e.Positives, func(i, j int) bool {
+			return e.Positives[i].ID < e.Positives[j].ID
+		})
+
+		hits, err := cfg.Retriever.Retrieve(ctx, q.Text, depth)
+		if err != nil {
+			return nil, stats, fmt.Errorf("retrieving query %s: %w", q.ID, err)
+		}
+
+		var candidates []retrieval.Document
+		for _, h := range hits {
+			if grades[h.ID] > 0 {
+				stats.Positives++
+				continue
+			}
+			candidates = append(candidates, h.Document)
+		}
+
+		if cfg.Ranker != nil {
+			var dropped int
+			candidates, dropped, err = filterFalseNegatives(ctx, cfg.Ranker, maxScore, q.Text, candidates, numNegatives)
+			if err != nil {
+				return nil, stats, fmt.Errorf("scoring query %s: %w", q.ID, err)
+			}
+			stats.FalseNegatives += dropped
+		} else if len(candidates) > numNegatives {
+			candidates = candidates[:numNegatives]
+		}
+
+		if len(candidates) == 0 {
+			stats.Skipped++
+			continue
+		}
+		e.Negatives = candidates
+
+		examples = append(examples, e)
+		stats.Queries++
+	}
+	return examples, stats, nil
+}
+
+// filterFalseNegatives returns the first n candidates scored below maxScore
+// by ranker, along with the number of the dropped ones. The candidates are
+// scored n at a time, to avoid scoring those that will not be used.
+func filterFalseNegatives(ctx context.Context, ranker retrieval.Ranker, maxScore float32, query string, candidates []retrieval.Document, n int) ([]retrieval.Document, int, error) {
+	var kept []retrieval.Document
+	var dropped int
+	for start := 0; start < len(candidates) && len(kept) < n; start += n {
+		chunk := candidates[start:min(start+n, len(candidates))]
+
+		paras := make([]string, len(chunk))
+		titles := make([]string, len(chunk))
+		for i, c := range chunk {
+			paras[i], titles[i] = c.Para, c.Title
+		}
+
+		scores, err := ranker.RankQueryContext(ctx, query, paras, titles)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(scores) != len(chunk) {
+			return nil, 0, fmt.Errorf("got %d scores, want %d", len(scores), len(chunk))
+		}
+
+		for i, c := range chunk {
+			switch {
+			case scores[i] >= maxScore:
+				dropped++
+			case len(kept) < n:
+				kept = append(kept, c)
+			}
+		}
+	}
+	return kept, dropped, nil
+}
@@ -0,0 +1,176 @@
+package mining_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-aie/rocketqa/eval"
+	"github.com/go-aie/rocketqa/mining"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/retrieval/retrievaltest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMine(t *testing.T) {
+	corpus := []retrieval.Document{
+		{ID: "d1", Title: "t1", Para: "p1"},
+		{ID: "d2", Para: "p2"},
+		{ID: "d3", Para: "p3"},
+		{ID: "d4", Para: "p4"},
+	}
+	queries := []eval.Query{
+		{ID: "q1", Text: "query one"},
+		{ID: "q2", Text: "query two"},   // The positive is not in the corpus.
+		{ID: "q3", Text: "query three"}, // No positives.
+	}
+	qrels := eval.Qrels{
+		"q1": {"d1": 1, "d4": 0},
+		"q2": {"d9": 1},
+		"q3": {"d1": 0},
+	}
+	// The candidates of every query, best first.
+	retriever := retrieval.RetrieverFunc(func(ctx context.Context, query string, k int) ([]retrieval.Hit, error) {
+		var hits []retrieval.Hit
+		for _, d := range corpus[:k] {
+			hits = append(hits, retrieval.Hit{Document: d})
+		}
+		return hits, nil
+	})
+
+	maxScore, negativeMaxScore := float32(0.9), float32(-2)
+
+	tests := []struct {
+		name      string
+		cfg       *mining.Config
+		want      []mining.Example
+		wantStats mining.Stats
+	}{
+		{
+			name: "top negatives",
+			cfg:  &mining.Config{Retriever: retriever, Depth: 4, Negatives: 2},
+			want: []mining.Example{
+				{
+					Query:     "query one",
+					Positives: []retrieval.Document{corpus[0]},
+					Negatives: []retrieval.Document{corpus[1], corpus[2]},
+				},
+			},
+			wantStats: mining.Stats{Queries: 1, Skipped: 1, Positives: 1},
+		},
+		{
+			name: "false negatives filtered",
+			cfg: &mining.Config{
+				Retriever: retriever,
+				Depth:     4,
+				Negatives: 2,
+				Ranker:    retrievaltest.Ranker{"p2": 0.95, "p3": 0.1, "p4": 0.2},
+				MaxScore:  &maxScore,
+			},
+			want: []mining.Example{
+				{
+					Query:     "query one",
+					Positives: []retrieval.Document{corpus[0]},
+					// The judged negative d4 is kept.
+					Negatives: []retrieval.Document{corpus[2], corpus[3]},
+				},
+			},
+			wantStats: mining.Stats{Queries: 1, Skipped: 1, Positives: 1, FalseNegatives: 1},
+		},
+		{
+			name: "default max score",
+			cfg: &mining.Config{
+				Retriever: retriever,
+				Depth:     4,
+				Negatives: 2,
+				Ranker:    retrievaltest.Ranker{"p2": 0.95, "p3": 0.1, "p4": 0.2},
+			},
+			want: []mining.Example{
+				{
+					Query:     "query one",
+					Positives: []retrieval.Document{corpus[0]},
+					Negatives: []retrieval.Document{corpus[2], corpus[3]},
+				},
+			},
+			wantStats: mining.Stats{Queries: 1, Skipped: 1, Positives: 1, FalseNegatives: 1},
+		},
+		{
+			name: "negative max score",
+			cfg: &mining.Config{
+				Retriever: retriever,
+				Depth:     4,
+				Negatives: 2,
+				Ranker:    retrievaltest.Ranker{"p2": 3.2, "p3": -2.5, "p4": -1.5},
+				MaxScore:  &negativeMaxScore,
+			},
+			want: []mining.Example{
+				{
+					Query:     "query one",
+					Positives: []retrieval.Document{corpus[0]},
+					Negatives: []retrieval.Document{corpus[2]},
+				},
+			},
+			wantStats: mining.Stats{Queries: 1, Skipped: 1, Positives: 1, FalseNegatives: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotStats, err := mining.Mine(context.Background(), tt.cfg, corpus, queries, qrels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(got, tt.want) {
+				diff := cmp.Diff(tt.want, got)
+				t.Errorf("Want - Got: %s", diff)
+			}
+			if !cmp.Equal(gotStats, tt.wantStats) {
+				diff := cmp.Diff(tt.wantStats, gotStats)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	examples := []mining.Example{
+		{
+			Query:     "query",
+			Positives: []retrieval.Document{{ID: "d1", Title: "t1", Para: "p1"}},
+			Negatives: []retrieval.Document{{ID: "d2", Para: "p2\twith a tab"}, {ID: "d3", Title: "t3", Para: "p3"}},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		write func(buf *bytes.Buffer) error
+		want  string
+	}{
+		{
+			name:  "cross",
+			write: func(buf *bytes.Buffer) error { return mining.WriteCross(buf, examples) },
+			want: "query\tt1\tp1\t1\n" +
+				"query\t-\tp2 with a tab\t0\n" +
+				"query\tt3\tp3\t0\n",
+		},
+		{
+			name:  "dual",
+			write: func(buf *bytes.Buffer) error { return mining.WriteDual(buf, examples) },
+			want: "query\tt1\tp1\t-\tp2 with a tab\t0\n" +
+				"query\tt1\tp1\tt3\tp3\t0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				diff := cmp.Diff(tt.want, got)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
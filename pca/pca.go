@@ -0,0 +1,164 @@
+// Package pca implements principal component analysis for reducing the
+// dimensionality of the embeddings produced by the encoders.
+//
+// A PCA fitted on a sample corpus can be attached to a DualEncoder as its
+// output transform (see DualEncoderConfig.Transform in package rocketqa), so that
+// queries and paragraphs always get the same projection.
+package pca
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// PCA projects vectors onto the principal components of the data it was
+// fitted on.
+type PCA struct {
+	// Mean is the mean of the fitted data, which is subtracted from every
+	// vector before the projection.
+	Mean []float32
+	// Components holds the principal components in descending order of
+	// explained variance, one per row.
+	Components [][]float32
+	// Variances holds the variance explained by each component.
+	Variances []float32
+}
+
+// Fit computes the first k principal components of the given samples.
+func Fit[V ~[]float32](samples []V, k int) (*PCA, error) {
+	n := len(samples)
+	if n < 2 {
+		return nil, errors.New("need at least two samples to fit")
+	}
+
+	dim := len(samples[0])
+	if k <= 0 || k > dim || k > n {
+		return nil, fmt.Errorf("k must be in [1, min(%d, %d)]", dim, n)
+	}
+
+	data := mat.NewDense(n, dim, nil)
+	for i, s := range samples {
+		if len(s) != dim {
+			return nil, errors.New("samples have different dimensions")
+		}
+		for j, x := range s {
+			data.Set(i, j, float64(x))
+		}
+	}
+
+	var pc stat.PC
+	if ok := pc.PrincipalComponents(data, nil); !ok {
+		return nil, errors.New("failed to compute principal components")
+	}
+	var vecs mat.Dense
+	pc.VectorsTo(&vecs)
+	vars := pc.VarsTo(nil)
+
+	mean := make([]float32, dim)
+	for j := range mean {
+		mean[j] = float32(stat.Mean(mat.Col(nil, j, data), nil))
+	}
+
+	components := make([][]float32, k)
+	for i := range components {
+		// The principal components are the columns of vecs.
+		components[i] = toFloat32(mat.Col(nil, i, &vecs))
+	}
+
+	return &PCA{
+		Mean:       mean,
+		Components: components,
+		Variances:  toFloat32(vars[:k]),
+	}, nil
+}
+
+// InputDim returns the dimension of the vectors accepted by Transform.
+func (p *PCA) InputDim() int {
+	return len(p.Mean)
+}
+
+// OutputDim returns the dimension of the vectors returned by Transform.
+func (p *PCA) OutputDim() int {
+	return len(p.Components)
+}
+
+// Transform projects v onto the principal components.
+func (p *PCA) Transform(v []float32) []float32 {
+	if len(v) != p.InputDim() {
+		panic("pca: dimension mismatch")
+	}
+
+	centered := make([]float32, len(v))
+	for i, x := range v {
+		centered[i] = x - p.Mean[i]
+	}
+
+	out := make([]float32, len(p.Components))
+	for i, c := range p.Components {
+		var s float32
+		for j, x := range centered {
+			s += x * c[j]
+		}
+		out[i] = s
+	}
+	return out
+}
+
+// Fingerprint returns the hex-encoded SHA-256 hash of the mean and the
+// components, which identifies the projection in the fingerprint of the
+// DualEncoder using it (see DualEncoder.Fingerprint in package rocketqa).
+func (p *PCA) Fingerprint() string {
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, p.Mean)
+	for _, c := range p.Components {
+		binary.Write(h, binary.LittleEndian, c)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// InverseTransform maps the projected vector v back to the original space,
+// which is useful for measuring the reconstruction error.
+func (p *PCA) InverseTransform(v []float32) []float32 {
+	if len(v) != p.OutputDim() {
+		panic("pca: dimension mismatch")
+	}
+
+	out := make([]float32, p.InputDim())
+	copy(out, p.Mean)
+	for i, x := range v {
+		for j, c := range p.Components[i] {
+			out[j] += x * c
+		}
+	}
+	return out
+}
+
+// Save writes p to w.
+func (p *PCA) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(p)
+}
+
+// Load reads a PCA previously written by Save from r.
+func Load(r io.Reader) (*PCA, error) {
+	p := new(PCA)
+	if err := gob.NewDecoder(r).Decode(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func toFloat32(v []float64) []float32 {
+	result := make([]float32, len(v))
+	for i, x := range v {
+		result[i] = float32(x)
+	}
+	return result
+}
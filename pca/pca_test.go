@@ -0,0 +1,152 @@
+package pca_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/pca"
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+type Vector []float32
+
+func TestFit(t *testing.T) {
+	samples := lowRankData(1, 200, 3, 16)
+
+	p, err := pca.Fit(samples, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.InputDim() != 16 || p.OutputDim() != 3 {
+		t.Fatalf("Got dims (%d, %d), Want (16, 3)", p.InputDim(), p.OutputDim())
+	}
+
+	for i := 1; i < len(p.Variances); i++ {
+		if p.Variances[i] > p.Variances[i-1] {
+			t.Errorf("Want variances in descending order, got %v", p.Variances)
+		}
+	}
+
+	// The samples lie (almost) on a 3-dim subspace, thus 3 components
+	// must be enough to reconstruct them.
+	for _, s := range samples {
+		got := p.InverseTransform(p.Transform(s))
+		if d := vecmath.L2(got, s); d > 0.05 {
+			t.Errorf("Got reconstruction error (%v) > 0.05", d)
+		}
+	}
+}
+
+func TestPCA_SaveLoad(t *testing.T) {
+	samples := lowRankData(1, 50, 4, 8)
+	p, err := pca.Fit(samples, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := pca.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cmp.Equal(loaded, p) {
+		diff := cmp.Diff(loaded, p)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestPCA_Fingerprint(t *testing.T) {
+	p1, err := pca.Fit(lowRankData(1, 50, 4, 8), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := pca.Fit(lowRankData(2, 50, 4, 8), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := p1.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := pca.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Fingerprint() != p1.Fingerprint() {
+		t.Errorf("Want the same fingerprint after loading")
+	}
+	if p2.Fingerprint() == p1.Fingerprint() {
+		t.Errorf("Want different fingerprints for different projections")
+	}
+}
+
+func TestFit_Error(t *testing.T) {
+	samples := lowRankData(1, 10, 2, 8)
+
+	tests := []struct {
+		name      string
+		inSamples []Vector
+		inK       int
+	}{
+		{"no samples", nil, 2},
+		{"zero k", samples, 0},
+		{"k too large", samples, 9},
+		{"mismatched dimensions", append(samples, Vector{1}), 2},
+	}
+	for _, tt := range tests {
+		if _, err := pca.Fit(tt.inSamples, tt.inK); err == nil {
+			t.Errorf("%s: Want error", tt.name)
+		}
+	}
+}
+
+func BenchmarkPCA_Transform(b *testing.B) {
+	samples := lowRankData(1, 1000, 64, 768)
+	p, err := pca.Fit(samples, 128)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = p.Transform(samples[i%len(samples)])
+	}
+}
+
+// lowRankData generates vectors of the given dimension that lie on a random
+// subspace of the given rank, plus a little noise.
+func lowRankData(seed int64, n, rank, dim int) []Vector {
+	r := rand.New(rand.NewSource(seed))
+
+	basis := make([]Vector, rank)
+	for i := range basis {
+		basis[i] = make(Vector, dim)
+		for j := range basis[i] {
+			basis[i][j] = float32(r.NormFloat64())
+		}
+	}
+
+	samples := make([]Vector, n)
+	for i := range samples {
+		v := make(Vector, dim)
+		for _, b := range basis {
+			w := float32(r.NormFloat64())
+			for j := range v {
+				v[j] += w * b[j]
+			}
+		}
+		for j := range v {
+			v[j] += float32(r.NormFloat64() * 0.001)
+		}
+		samples[i] = v
+	}
+	return samples
+}
@@ -1,10 +1,21 @@
 package rocketqa
 
+import (
+	"context"
+)
+
 // QPT is a structured type that represents a single parameter group.
 type QPT struct {
 	Query string
 	Para  string
 	Title string
+	// An optional ID, which is carried through to the results of the typed
+	// methods, such as DualEncoder.EncodeParaQPTs and CrossEncoder.RankQPTs.
+	ID string
+	// Optional metadata of the caller, which is carried through along with
+	// ID. It is never read by the encoders. Note that comparing QPTs with ==
+	// panics if Metadata holds a value that is not comparable, such as a map.
+	Metadata any
 }
 
 // QPTs is a helper type that makes it easy to construct parameters in a more structured way.
@@ -33,3 +44,69 @@ func (qs QPTs) T() (titles []string) {
 	}
 	return
 }
+
+// EncodedQPT is a QPT along with its vector.
+type EncodedQPT struct {
+	QPT
+	Vector Vector
+}
+
+// ScoredQPT is a QPT along with its relevance score.
+type ScoredQPT struct {
+	QPT
+	Score float32
+}
+
+// EncodeQueryQPTs is like EncodeQueries, but encodes the Query fields of
+// qpts, and returns every vector along with its QPT, in the input order.
+func (de *DualEncoder) EncodeQueryQPTs(ctx context.Context, qpts QPTs) ([]EncodedQPT, error) {
+	vectors, err := de.EncodeQueries(ctx, qpts.Q())
+	if err != nil {
+		return nil, err
+	}
+	return zipVectors(qpts, vectors), nil
+}
+
+// EncodeParaQPTs is like EncodeParaContext, but encodes the Para and Title
+// fields of qpts, and returns every vector along with its QPT, in the input
+// order.
+func (de *DualEncoder) EncodeParaQPTs(ctx context.Context, qpts QPTs) ([]EncodedQPT, error) {
+	vectors, err := de.EncodeParaContext(ctx, qpts.P(), qpts.T())
+	if err != nil {
+		return nil, err
+	}
+	return zipVectors(qpts, vectors), nil
+}
+
+// RankQPTs is like RankContext, but scores the (Query, Para, Title) fields of
+// qpts, and returns every score along with its QPT, in the input order. Use
+// Rerank to sort the results.
+func (ce *CrossEncoder) RankQPTs(ctx context.Context, qpts QPTs) ([]ScoredQPT, error) {
+	scores, err := ce.RankContext(ctx, qpts.Q(), qpts.P(), qpts.T())
+	if err != nil {
+		return nil, err
+	}
+	return zipScores(qpts, scores), nil
+}
+
+func zipVectors(qpts QPTs, vectors []Vector) []EncodedQPT {
+	if len(vectors) == 0 {
+		return nil
+	}
+	results := make([]EncodedQPT, len(qpts))
+	for i, q := range qpts {
+		results[i] = EncodedQPT{QPT: q, Vector: vectors[i]}
+	}
+	return results
+}
+
+func zipScores(qpts QPTs, scores []float32) []ScoredQPT {
+	if len(scores) == 0 {
+		return nil
+	}
+	results := make([]ScoredQPT, len(qpts))
+	for i, q := range qpts {
+		results[i] = ScoredQPT{QPT: q, Score: scores[i]}
+	}
+	return results
+}
@@ -0,0 +1,91 @@
+package rocketqa_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+var testQPTs = rocketqa.QPTs{
+	{
+		ID:       "a",
+		Query:    "你好，世界！",
+		Para:     "这是一段较长的文本。",
+		Title:    "标题",
+		Metadata: "zh",
+	},
+	{
+		ID:       "b",
+		Query:    "Hello, World!",
+		Para:     "This is a long paragraph.",
+		Metadata: "en",
+	},
+}
+
+func TestDualEncoder_EncodeQPTs(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queryVectors, err := de.EncodeQueries(context.Background(), testQPTs.Q())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantQueries []rocketqa.EncodedQPT
+	for i, v := range queryVectors {
+		wantQueries = append(wantQueries, rocketqa.EncodedQPT{QPT: testQPTs[i], Vector: v})
+	}
+	gotQueries, err := de.EncodeQueryQPTs(context.Background(), testQPTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(gotQueries, wantQueries) {
+		diff := cmp.Diff(wantQueries, gotQueries)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	vectors, err := de.EncodePara(testQPTs.P(), testQPTs.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantParas []rocketqa.EncodedQPT
+	for i, v := range vectors {
+		wantParas = append(wantParas, rocketqa.EncodedQPT{QPT: testQPTs[i], Vector: v})
+	}
+	gotParas, err := de.EncodeParaQPTs(context.Background(), testQPTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(gotParas, wantParas) {
+		diff := cmp.Diff(wantParas, gotParas)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestCrossEncoder_RankQPTs(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err := ce.Rank(testQPTs.Q(), testQPTs.P(), testQPTs.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []rocketqa.ScoredQPT
+	for i, s := range scores {
+		want = append(want, rocketqa.ScoredQPT{QPT: testQPTs[i], Score: s})
+	}
+
+	got, err := ce.RankQPTs(context.Background(), testQPTs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
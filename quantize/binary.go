@@ -0,0 +1,119 @@
+package quantize
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// BinaryQuantizer is a sign quantizer, which keeps only one bit per
+// dimension, telling whether the value is above the threshold of that
+// dimension.
+//
+// An encoded vector takes a thirty-second of the memory of its float32 form.
+type BinaryQuantizer struct {
+	// Threshold is the value used to binarize each dimension. A zero
+	// threshold means plain sign quantization.
+	Threshold []float32
+}
+
+// NewBinary creates a BinaryQuantizer that binarizes vectors of the given
+// dimension by their signs.
+func NewBinary(dim int) *BinaryQuantizer {
+	return &BinaryQuantizer{Threshold: make([]float32, dim)}
+}
+
+// TrainBinary calibrates a BinaryQuantizer on the given sample of vectors,
+// by using the mean of each dimension as its threshold. Centering the
+// dimensions this way keeps the bits balanced for embeddings whose values
+// are not distributed around zero.
+func TrainBinary[V ~[]float32](vectors []V) (*BinaryQuantizer, error) {
+	if len(vectors) == 0 {
+		return nil, errors.New("no vectors to train on")
+	}
+
+	dim := len(vectors[0])
+	sum := make([]float64, dim)
+	for _, v := range vectors {
+		if len(v) != dim {
+			return nil, errors.New("vectors have different dimensions")
+		}
+		for i, x := range v {
+			sum[i] += float64(x)
+		}
+	}
+
+	threshold := make([]float32, dim)
+	for i, s := range sum {
+		threshold[i] = float32(s / float64(len(vectors)))
+	}
+	return &BinaryQuantizer{Threshold: threshold}, nil
+}
+
+// Dim returns the dimension of the vectors supported by q.
+func (q *BinaryQuantizer) Dim() int {
+	return len(q.Threshold)
+}
+
+// Encode quantizes v into a binary code, where the i-th bit is set if the
+// i-th value of v is above its threshold.
+func (q *BinaryQuantizer) Encode(v []float32) []uint64 {
+	mustDim(q.Dim(), len(v))
+
+	code := make([]uint64, (len(v)+63)/64)
+	for i, x := range v {
+		if x > q.Threshold[i] {
+			code[i/64] |= 1 << (i % 64)
+		}
+	}
+	return code
+}
+
+// Decode reconstructs the approximate vector represented by code, where
+// every value is either +1 or -1.
+func (q *BinaryQuantizer) Decode(code []uint64) []float32 {
+	mustDim((q.Dim()+63)/64, len(code))
+
+	v := make([]float32, q.Dim())
+	for i := range v {
+		v[i] = sign(code, i)
+	}
+	return v
+}
+
+// Dot returns the approximate dot product between the float vector query
+// and the ±1 vector represented by code.
+func (q *BinaryQuantizer) Dot(query []float32, code []uint64) float32 {
+	mustDim(q.Dim(), len(query))
+	mustDim((q.Dim()+63)/64, len(code))
+
+	var sum float32
+	for i, x := range query {
+		sum += x * sign(code, i)
+	}
+	return sum
+}
+
+// Hamming returns the number of differing bits between the codes a and b.
+// A smaller distance means a higher similarity.
+func Hamming(a, b []uint64) int {
+	mustDim(len(a), len(b))
+
+	var d int
+	for i := range a {
+		d += bits.OnesCount64(a[i] ^ b[i])
+	}
+	return d
+}
+
+// BinaryDot returns the dot product between the two ±1 vectors of the given
+// dimension represented by the codes a and b.
+func BinaryDot(a, b []uint64, dim int) int {
+	return dim - 2*Hamming(a, b)
+}
+
+func sign(code []uint64, i int) float32 {
+	if code[i/64]&(1<<(i%64)) != 0 {
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+package quantize_test
+
+import (
+	"testing"
+
+	"github.com/go-aie/rocketqa/quantize"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBinaryQuantizer(t *testing.T) {
+	q := quantize.NewBinary(3)
+
+	tests := []struct {
+		in          Vector
+		wantCode    []uint64
+		wantDecoded []float32
+	}{
+		{
+			in:          Vector{0.5, -0.2, 0.1},
+			wantCode:    []uint64{0b101},
+			wantDecoded: []float32{1, -1, 1},
+		},
+		{
+			in:          Vector{-0.5, 0, 0.3},
+			wantCode:    []uint64{0b100},
+			wantDecoded: []float32{-1, -1, 1},
+		},
+	}
+	for _, tt := range tests {
+		gotCode := q.Encode(tt.in)
+		if !cmp.Equal(gotCode, tt.wantCode) {
+			diff := cmp.Diff(gotCode, tt.wantCode)
+			t.Errorf("Code (Want - Got): %s", diff)
+		}
+
+		gotDecoded := q.Decode(gotCode)
+		if !cmp.Equal(gotDecoded, tt.wantDecoded) {
+			diff := cmp.Diff(gotDecoded, tt.wantDecoded)
+			t.Errorf("Decoded (Want - Got): %s", diff)
+		}
+	}
+
+	a, b := q.Encode(tests[0].in), q.Encode(tests[1].in)
+	if got := quantize.Hamming(a, b); got != 1 {
+		t.Errorf("Hamming: Got (%v) != Want (%v)", got, 1)
+	}
+	if got := quantize.BinaryDot(a, b, q.Dim()); got != 1 {
+		t.Errorf("BinaryDot: Got (%v) != Want (%v)", got, 1)
+	}
+	if got := q.Dot([]float32{1, 2, 3}, a); got != 2 {
+		t.Errorf("Dot: Got (%v) != Want (%v)", got, 2)
+	}
+}
+
+func TestTrainBinary(t *testing.T) {
+	q, err := quantize.TrainBinary([]Vector{
+		{1, 10},
+		{3, 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []float32{2, 15}
+	if !cmp.Equal(q.Threshold, want) {
+		diff := cmp.Diff(q.Threshold, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// 70 dimensions span two words.
+	q = quantize.NewBinary(70)
+	v := make([]float32, 70)
+	v[69] = 1
+	if got := q.Encode(v); !cmp.Equal(got, []uint64{0, 1 << 5}) {
+		t.Errorf("Got (%v)", got)
+	}
+}
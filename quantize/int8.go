@@ -0,0 +1,112 @@
+// Package quantize provides quantizers that compress the embeddings produced
+// by the encoders for compact storage and approximate search.
+package quantize
+
+import (
+	"errors"
+	"math"
+)
+
+// Int8Quantizer is a scalar quantizer, which maps every dimension linearly
+// onto the 256 values of an int8, using the per-dimension value ranges
+// calibrated from a sample of vectors.
+//
+// An encoded vector takes a quarter of the memory of its float32 form.
+type Int8Quantizer struct {
+	// Min is the minimum value of each dimension.
+	Min []float32
+	// Step is the width of one quantization level of each dimension.
+	Step []float32
+}
+
+// TrainInt8 calibrates an Int8Quantizer on the given sample of vectors.
+func TrainInt8[V ~[]float32](vectors []V) (*Int8Quantizer, error) {
+	if len(vectors) == 0 {
+		return nil, errors.New("no vectors to train on")
+	}
+
+	dim := len(vectors[0])
+	lo := make([]float32, dim)
+	hi := make([]float32, dim)
+	copy(lo, vectors[0])
+	copy(hi, vectors[0])
+
+	for _, v := range vectors[1:] {
+		if len(v) != dim {
+			return nil, errors.New("vectors have different dimensions")
+		}
+		for i, x := range v {
+			if x < lo[i] {
+				lo[i] = x
+			}
+			if x > hi[i] {
+				hi[i] = x
+			}
+		}
+	}
+
+	step := make([]float32, dim)
+	for i := range step {
+		step[i] = (hi[i] - lo[i]) / 255
+	}
+
+	return &Int8Quantizer{Min: lo, Step: step}, nil
+}
+
+// Dim returns the dimension of the vectors supported by q.
+func (q *Int8Quantizer) Dim() int {
+	return len(q.Min)
+}
+
+// Encode quantizes v into an int8 code. Values outside the calibrated range
+// are clamped.
+func (q *Int8Quantizer) Encode(v []float32) []int8 {
+	mustDim(q.Dim(), len(v))
+
+	code := make([]int8, len(v))
+	for i, x := range v {
+		if q.Step[i] == 0 {
+			code[i] = math.MinInt8
+			continue
+		}
+		level := math.Round(float64((x - q.Min[i]) / q.Step[i]))
+		if level < 0 {
+			level = 0
+		} else if level > 255 {
+			level = 255
+		}
+		code[i] = int8(level - 128)
+	}
+	return code
+}
+
+// Decode reconstructs the approximate vector represented by code.
+func (q *Int8Quantizer) Decode(code []int8) []float32 {
+	mustDim(q.Dim(), len(code))
+
+	v := make([]float32, len(code))
+	for i, c := range code {
+		v[i] = q.Min[i] + q.Step[i]*float32(int(c)+128)
+	}
+	return v
+}
+
+// Dot returns the approximate dot product between the float vector query
+// and the vector represented by code, without decoding code.
+func (q *Int8Quantizer) Dot(query []float32, code []int8) float32 {
+	mustDim(q.Dim(), len(query))
+	mustDim(q.Dim(), len(code))
+
+	var bias, sum float32
+	for i, x := range query {
+		bias += x * q.Min[i]
+		sum += x * q.Step[i] * float32(int(code[i])+128)
+	}
+	return bias + sum
+}
+
+func mustDim(want, got int) {
+	if want != got {
+		panic("quantize: dimension mismatch")
+	}
+}
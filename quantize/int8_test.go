@@ -0,0 +1,76 @@
+package quantize_test
+
+import (
+	"testing"
+
+	"github.com/go-aie/rocketqa/quantize"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type Vector []float32
+
+func TestInt8Quantizer(t *testing.T) {
+	q, err := quantize.TrainInt8([]Vector{
+		{-1, 0, 2},
+		{1, 0, 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		in          Vector
+		wantCode    []int8
+		wantDecoded []float32
+	}{
+		{
+			in:          Vector{-1, 0, 2},
+			wantCode:    []int8{-128, -128, -128},
+			wantDecoded: []float32{-1, 0, 2},
+		},
+		{
+			in:          Vector{1, 0, 4},
+			wantCode:    []int8{127, -128, 127},
+			wantDecoded: []float32{1, 0, 4},
+		},
+		{
+			// Out-of-range values are clamped.
+			in:          Vector{5, 1, 3.5},
+			wantCode:    []int8{127, -128, 63},
+			wantDecoded: []float32{1, 0, 3.498039},
+		},
+	}
+	opt := cmpopts.EquateApprox(0, 1e-5)
+	for _, tt := range tests {
+		gotCode := q.Encode(tt.in)
+		if !cmp.Equal(gotCode, tt.wantCode) {
+			diff := cmp.Diff(gotCode, tt.wantCode)
+			t.Errorf("Code (Want - Got): %s", diff)
+		}
+
+		gotDecoded := q.Decode(gotCode)
+		if !cmp.Equal(gotDecoded, tt.wantDecoded, opt) {
+			diff := cmp.Diff(gotDecoded, tt.wantDecoded)
+			t.Errorf("Decoded (Want - Got): %s", diff)
+		}
+
+		query := []float32{0.5, -1, 2}
+		var wantDot float32
+		for i, x := range tt.wantDecoded {
+			wantDot += query[i] * x
+		}
+		if gotDot := q.Dot(query, gotCode); !cmp.Equal(gotDot, wantDot, opt) {
+			t.Errorf("Dot: Got (%v) != Want (%v)", gotDot, wantDot)
+		}
+	}
+}
+
+func TestTrainInt8_Error(t *testing.T) {
+	if _, err := quantize.TrainInt8([]Vector{}); err == nil {
+		t.Errorf("Want error for no vectors")
+	}
+	if _, err := quantize.TrainInt8([]Vector{{1, 2}, {1}}); err == nil {
+		t.Errorf("Want error for mismatched dimensions")
+	}
+}
@@ -0,0 +1,141 @@
+package quantize_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/quantize"
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+const (
+	recallDim     = 256
+	recallCorpus  = 2000
+	recallQueries = 50
+	recallK       = 10
+)
+
+// TestRecall measures how well the rankings computed on the quantized codes
+// agree with the ones computed on the original float vectors.
+func TestRecall(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	corpus := randomUnitVectors(r, recallCorpus, recallDim)
+	queries := randomUnitVectors(r, recallQueries, recallDim)
+
+	int8Q, err := quantize.TrainInt8(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	int8Codes := make([][]int8, len(corpus))
+	for i, v := range corpus {
+		int8Codes[i] = int8Q.Encode(v)
+	}
+
+	binQ, err := quantize.TrainBinary(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binCodes := make([][]uint64, len(corpus))
+	for i, v := range corpus {
+		binCodes[i] = binQ.Encode(v)
+	}
+
+	tests := []struct {
+		name       string
+		score      func(query Vector, i int) float32
+		wantRecall float64
+	}{
+		{
+			name:       "int8",
+			score:      func(query Vector, i int) float32 { return int8Q.Dot(query, int8Codes[i]) },
+			wantRecall: 0.9,
+		},
+		{
+			name:       "binary",
+			score:      func(query Vector, i int) float32 { return binQ.Dot(query, binCodes[i]) },
+			wantRecall: 0.25,
+		},
+	}
+	for _, tt := range tests {
+		var total float64
+		for _, query := range queries {
+			want := vecmath.TopKVectors(query, corpus, recallK)
+
+			scores := make([]float32, len(corpus))
+			for i := range corpus {
+				scores[i] = tt.score(query, i)
+			}
+			got := vecmath.TopK(scores, recallK)
+
+			total += recall(want, got)
+		}
+
+		gotRecall := total / float64(len(queries))
+		t.Logf("%s: recall@%d = %.3f", tt.name, recallK, gotRecall)
+		if gotRecall < tt.wantRecall {
+			t.Errorf("%s: Got recall (%.3f) < Want (%.3f)", tt.name, gotRecall, tt.wantRecall)
+		}
+	}
+}
+
+func BenchmarkInt8Quantizer_Dot(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	vs := randomUnitVectors(r, 100, 768)
+	q, _ := quantize.TrainInt8(vs)
+	code := q.Encode(vs[0])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = q.Dot(vs[1], code)
+	}
+}
+
+func BenchmarkBinaryQuantizer_Dot(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	vs := randomUnitVectors(r, 100, 768)
+	q, _ := quantize.TrainBinary(vs)
+	code := q.Encode(vs[0])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = q.Dot(vs[1], code)
+	}
+}
+
+func BenchmarkHamming(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	vs := randomUnitVectors(r, 2, 768)
+	q := quantize.NewBinary(768)
+	x, y := q.Encode(vs[0]), q.Encode(vs[1])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = quantize.Hamming(x, y)
+	}
+}
+
+func recall(want, got []vecmath.Match) float64 {
+	set := make(map[int]bool, len(want))
+	for _, m := range want {
+		set[m.Index] = true
+	}
+	var hits int
+	for _, m := range got {
+		if set[m.Index] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(want))
+}
+
+func randomUnitVectors(r *rand.Rand, n, dim int) []Vector {
+	vs := make([]Vector, n)
+	for i := range vs {
+		v := make(Vector, dim)
+		for j := range v {
+			v[j] = float32(r.NormFloat64())
+		}
+		vs[i] = vecmath.Normalize(v)
+	}
+	return vs
+}
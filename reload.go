@@ -0,0 +1,501 @@
+package rocketqa
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reloadable holds the current encoder, and replaces it on reload. E is the
+// type of the encoder, and C is the type of its config.
+type reloadable[E io.Closer, C any] struct {
+	reloadMu sync.Mutex // Serializes reloads.
+	current  atomic.Pointer[generation[E, C]]
+}
+
+// generation is one loaded encoder, along with the config it is built from.
+type generation[E io.Closer, C any] struct {
+	// mu is held for reading by the in-flight requests, and for writing by
+	// the reload that retires the generation.
+	mu      sync.RWMutex
+	retired bool
+
+	encoder E
+	cfg     C
+}
+
+// acquire returns the current generation, which must be released after use.
+func (r *reloadable[E, C]) acquire() *generation[E, C] {
+	for {
+		g := r.current.Load()
+		g.mu.RLock()
+		if !g.retired {
+			return g
+		}
+		// A reload has just replaced g, try again with the new one.
+		g.mu.RUnlock()
+	}
+}
+
+func (g *generation[E, C]) release() {
+	g.mu.RUnlock()
+}
+
+// reload builds a new encoder and runs smokeTest on it. If both succeed, the
+// new encoder replaces the current one, which is retired and closed after all
+// of its in-flight requests have finished. Otherwise, the new encoder is
+// closed and the current one is kept.
+func (r *reloadable[E, C]) reload(cfg C, build func(cfg C) (E, error), smokeTest func(E) error) error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	var encoder E
+	err := safely(func() (err error) {
+		encoder, err = build(cfg)
+		return err
+	})
+	if err != nil {
+		// The constructors already report ErrVocab or ErrModelLoad.
+		return err
+	}
+	if err := safely(func() error { return smokeTest(encoder) }); err != nil {
+		encoder.Close()
+		return fmt.Errorf("model failed smoke test: %w", err)
+	}
+
+	old := r.current.Swap(&generation[E, C]{encoder: encoder, cfg: cfg})
+	if old != nil {
+		// Drain the in-flight requests.
+		old.mu.Lock()
+		old.retired = true
+		old.mu.Unlock()
+		old.encoder.Close()
+	}
+	return nil
+}
+
+// close closes the current encoder after all of its in-flight requests have
+// finished, as reload does when retiring it. The generation is kept current
+// instead of being retired, so that the later requests acquire it and fail
+// with ErrClosed, until a reload replaces it.
+func (r *reloadable[E, C]) close() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	g := r.current.Load()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.encoder.Close()
+}
+
+// safely calls f, turning panics (e.g. from the inference engine on a
+// broken model) into errors.
+func safely(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f()
+}
+
+// ReloadableDualEncoderConfig is the config of ReloadableDualEncoder. Set
+// DualEncoderConfig.WarmUp to warm up every new model before it takes over.
+type ReloadableDualEncoderConfig struct {
+	DualEncoderConfig
+	// SmokeTest checks a newly loaded encoder before it replaces the current
+	// one. Defaults to encoding a sample query and paragraph, and checking
+	// that the vectors are non-empty and finite.
+	SmokeTest func(de *DualEncoder) error
+}
+
+// ReloadableDualEncoder is a DualEncoder whose model can be replaced without
+// interrupting the requests, e.g. after fine-tuning a new model.
+type ReloadableDualEncoder struct {
+	r         reloadable[*DualEncoder, *DualEncoderConfig]
+	smokeTest func(de *DualEncoder) error
+}
+
+func NewReloadableDualEncoder(cfg *ReloadableDualEncoderConfig) (*ReloadableDualEncoder, error) {
+	smokeTest := cfg.SmokeTest
+	if smokeTest == nil {
+		smokeTest = smokeTestDualEncoder
+	}
+
+	de := &ReloadableDualEncoder{smokeTest: smokeTest}
+	if err := de.Reload(&cfg.DualEncoderConfig); err != nil {
+		return nil, err
+	}
+	return de, nil
+}
+
+// Reload replaces the current model with the one specified by cfg. If the
+// new model fails to load or fails the smoke test, the current one is kept
+// and an error is returned.
+func (de *ReloadableDualEncoder) Reload(cfg *DualEncoderConfig) error {
+	c := *cfg
+	return de.r.reload(&c, NewDualEncoder, de.smokeTest)
+}
+
+// ReloadDir is like Reload, but loads the model files in dir (see
+// FindModelFiles), keeping the other settings of the current config.
+func (de *ReloadableDualEncoder) ReloadDir(dir string) error {
+	files, err := FindModelFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	cfg := de.Config()
+	cfg.ModelPath, cfg.ParamsPath = files.ModelPath, files.ParamsPath
+	if files.VocabFile != "" {
+		cfg.VocabFile = files.VocabFile
+	}
+	return de.Reload(&cfg)
+}
+
+// Watch polls dir every interval, and calls ReloadDir whenever the model
+// files in it have changed and stayed unchanged for one interval (to skip
+// partially copied files). The result of every reload is passed to onReload,
+// if not nil. Watch blocks until ctx is done.
+func (de *ReloadableDualEncoder) Watch(ctx context.Context, dir string, interval time.Duration, onReload func(err error)) {
+	watch(ctx, dir, interval, de.ReloadDir, onReload)
+}
+
+// Config returns the config of the current model.
+func (de *ReloadableDualEncoder) Config() DualEncoderConfig {
+	g := de.r.acquire()
+	defer g.release()
+	return *g.cfg
+}
+
+// Close closes the current model (see DualEncoder.Close), after all of its
+// in-flight requests have finished. Later requests fail with ErrClosed, until
+// a later Reload loads a new model.
+func (de *ReloadableDualEncoder) Close() error {
+	return de.r.close()
+}
+
+func (de *ReloadableDualEncoder) Fingerprint() string {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.Fingerprint()
+}
+
+// EncodeQuery is like EncodeQueries, but panics with the error, if any.
+//
+// Deprecated: Use EncodeQueries, which returns the errors instead.
+func (de *ReloadableDualEncoder) EncodeQuery(queries []string) []Vector {
+	return mustVectors(de.EncodeQueries(context.Background(), queries))
+}
+
+func (de *ReloadableDualEncoder) EncodePara(paras, titles []string) ([]Vector, error) {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.EncodePara(paras, titles)
+}
+
+// EncodeQueryContext is like EncodeQueries, but panics with the error, if
+// any.
+//
+// Deprecated: Use EncodeQueries, which returns the errors instead.
+func (de *ReloadableDualEncoder) EncodeQueryContext(ctx context.Context, queries []string) []Vector {
+	return mustVectors(de.EncodeQueries(ctx, queries))
+}
+
+func (de *ReloadableDualEncoder) EncodeQueries(ctx context.Context, queries []string) ([]Vector, error) {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.EncodeQueries(ctx, queries)
+}
+
+func (de *ReloadableDualEncoder) EncodeParaContext(ctx context.Context, paras, titles []string) ([]Vector, error) {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.EncodeParaContext(ctx, paras, titles)
+}
+
+func (de *ReloadableDualEncoder) EncodeQueryQPTs(ctx context.Context, qpts QPTs) ([]EncodedQPT, error) {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.EncodeQueryQPTs(ctx, qpts)
+}
+
+func (de *ReloadableDualEncoder) EncodeParaQPTs(ctx context.Context, qpts QPTs) ([]EncodedQPT, error) {
+	g := de.r.acquire()
+	defer g.release()
+	return g.encoder.EncodeParaQPTs(ctx, qpts)
+}
+
+func smokeTestDualEncoder(de *DualEncoder) error {
+	vectors, err := de.EncodeQueries(context.Background(), []string{smokeQuery})
+	if err != nil {
+		return err
+	}
+	paraVectors, err := de.EncodePara([]string{smokePara}, []string{smokeTitle})
+	if err != nil {
+		return err
+	}
+
+	for _, vs := range [][]Vector{vectors, paraVectors} {
+		if len(vs) != 1 || len(vs[0]) == 0 {
+			return fmt.Errorf("got no vectors")
+		}
+		for _, x := range vs[0] {
+			if !isFinite(x) {
+				return fmt.Errorf("got non-finite vector values")
+			}
+		}
+	}
+	return nil
+}
+
+// ReloadableCrossEncoderConfig is the config of ReloadableCrossEncoder. Set
+// CrossEncoderConfig.WarmUp to warm up every new model before it takes over.
+type ReloadableCrossEncoderConfig struct {
+	CrossEncoderConfig
+	// SmokeTest checks a newly loaded encoder before it replaces the current
+	// one. Defaults to ranking a sample (query, para) pair, and checking that
+	// the score is finite.
+	SmokeTest func(ce *CrossEncoder) error
+}
+
+// ReloadableCrossEncoder is a CrossEncoder whose model can be replaced
+// without interrupting the requests, e.g. after fine-tuning a new model.
+type ReloadableCrossEncoder struct {
+	r         reloadable[*CrossEncoder, *CrossEncoderConfig]
+	smokeTest func(ce *CrossEncoder) error
+}
+
+func NewReloadableCrossEncoder(cfg *ReloadableCrossEncoderConfig) (*ReloadableCrossEncoder, error) {
+	smokeTest := cfg.SmokeTest
+	if smokeTest == nil {
+		smokeTest = smokeTestCrossEncoder
+	}
+
+	ce := &ReloadableCrossEncoder{smokeTest: smokeTest}
+	if err := ce.Reload(&cfg.CrossEncoderConfig); err != nil {
+		return nil, err
+	}
+	return ce, nil
+}
+
+// Reload replaces the current model with the one specified by cfg. If the
+// new model fails to load or fails the smoke test, the current one is kept
+// and an error is returned.
+func (ce *ReloadableCrossEncoder) Reload(cfg *CrossEncoderConfig) error {
+	c := *cfg
+	return ce.r.reload(&c, NewCrossEncoder, ce.smokeTest)
+}
+
+// ReloadDir is like Reload, but loads the model files in dir (see
+// FindModelFiles), keeping the other settings of the current config.
+func (ce *ReloadableCrossEncoder) ReloadDir(dir string) error {
+	files, err := FindModelFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	cfg := ce.Config()
+	cfg.ModelPath, cfg.ParamsPath = files.ModelPath, files.ParamsPath
+	if files.VocabFile != "" {
+		cfg.VocabFile = files.VocabFile
+	}
+	return ce.Reload(&cfg)
+}
+
+// Watch polls dir every interval, and calls ReloadDir whenever the model
+// files in it have changed. See ReloadableDualEncoder.Watch for details.
+func (ce *ReloadableCrossEncoder) Watch(ctx context.Context, dir string, interval time.Duration, onReload func(err error)) {
+	watch(ctx, dir, interval, ce.ReloadDir, onReload)
+}
+
+// Config returns the config of the current model.
+func (ce *ReloadableCrossEncoder) Config() CrossEncoderConfig {
+	g := ce.r.acquire()
+	defer g.release()
+	return *g.cfg
+}
+
+// Close closes the current model (see CrossEncoder.Close), after all of its
+// in-flight requests have finished. Later requests fail with ErrClosed, until
+// a later Reload loads a new model.
+func (ce *ReloadableCrossEncoder) Close() error {
+	return ce.r.close()
+}
+
+func (ce *ReloadableCrossEncoder) Fingerprint() string {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.Fingerprint()
+}
+
+func (ce *ReloadableCrossEncoder) Rank(queries, paras, titles []string) ([]float32, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.Rank(queries, paras, titles)
+}
+
+func (ce *ReloadableCrossEncoder) RankQuery(query string, paras, titles []string) ([]float32, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.RankQuery(query, paras, titles)
+}
+
+func (ce *ReloadableCrossEncoder) RankContext(ctx context.Context, queries, paras, titles []string) ([]float32, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.RankContext(ctx, queries, paras, titles)
+}
+
+func (ce *ReloadableCrossEncoder) RankQueryContext(ctx context.Context, query string, paras, titles []string) ([]float32, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.RankQueryContext(ctx, query, paras, titles)
+}
+
+func (ce *ReloadableCrossEncoder) RankQPTs(ctx context.Context, qpts QPTs) ([]ScoredQPT, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.RankQPTs(ctx, qpts)
+}
+
+func (ce *ReloadableCrossEncoder) Logits(queries, paras, titles []string) ([]float32, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.Logits(queries, paras, titles)
+}
+
+func (ce *ReloadableCrossEncoder) Rerank(ctx context.Context, query string, candidates []Candidate, opts *RerankOptions) ([]RerankResult, error) {
+	g := ce.r.acquire()
+	defer g.release()
+	return g.encoder.Rerank(ctx, query, candidates, opts)
+}
+
+func smokeTestCrossEncoder(ce *CrossEncoder) error {
+	scores, err := ce.Rank([]string{smokeQuery}, []string{smokePara}, []string{smokeTitle})
+	if err != nil {
+		return err
+	}
+	if len(scores) != 1 || !isFinite(scores[0]) {
+		return fmt.Errorf("got invalid scores %v", scores)
+	}
+	return nil
+}
+
+// The sample inputs of the default smoke tests.
+const (
+	smokeQuery = "什么是深度学习？"
+	smokeTitle = "深度学习"
+	smokePara  = "深度学习是机器学习的一个分支，它使用多层神经网络从数据中学习表示。"
+)
+
+func isFinite(x float32) bool {
+	return !math.IsNaN(float64(x)) && !math.IsInf(float64(x), 0)
+}
+
+// ModelFiles holds the paths of the files of a model.
+type ModelFiles struct {
+	ModelPath  string
+	ParamsPath string
+	// Empty if the model directory has no vocab file.
+	VocabFile string
+}
+
+// FindModelFiles finds the model files in dir, which must contain exactly
+// one "*.pdmodel" file and one "*.pdiparams" file, and optionally one
+// "*vocab*.txt" file.
+func FindModelFiles(dir string) (ModelFiles, error) {
+	find := func(pattern string, required bool) (string, error) {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(matches) > 1:
+			return "", fmt.Errorf("found multiple %q files in %s", pattern, dir)
+		case len(matches) == 0 && required:
+			return "", fmt.Errorf("found no %q file in %s", pattern, dir)
+		case len(matches) == 0:
+			return "", nil
+		}
+		return matches[0], nil
+	}
+
+	var files ModelFiles
+	var err error
+	if files.ModelPath, err = find("*.pdmodel", true); err != nil {
+		return ModelFiles{}, err
+	}
+	if files.ParamsPath, err = find("*.pdiparams", true); err != nil {
+		return ModelFiles{}, err
+	}
+	if files.VocabFile, err = find("*vocab*.txt", false); err != nil {
+		return ModelFiles{}, err
+	}
+	return files, nil
+}
+
+// watch polls dir every interval, and calls reload when the model files have
+// changed and then stayed unchanged for one interval.
+func watch(ctx context.Context, dir string, interval time.Duration, reload func(dir string) error, onReload func(err error)) {
+	loaded, _ := dirSignature(dir)
+	last := loaded
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sig, err := dirSignature(dir)
+		if err != nil {
+			// The files may be in the middle of being replaced.
+			continue
+		}
+		stable := sig == last
+		last = sig
+		if !stable || sig == loaded {
+			continue
+		}
+
+		// Only retry a failed reload after the files change again.
+		loaded = sig
+		err = reload(dir)
+		if onReload != nil {
+			onReload(err)
+		}
+	}
+}
+
+// dirSignature summarizes the names, sizes and modification times of the
+// model files in dir.
+func dirSignature(dir string) (string, error) {
+	var parts []string
+	for _, pattern := range []string{"*.pdmodel", "*.pdiparams", "*vocab*.txt"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return "", err
+		}
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, fmt.Sprintf("%s:%d:%d", m, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|"), nil
+}
@@ -0,0 +1,222 @@
+package rocketqa_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-aie/rocketqa"
+)
+
+func TestReloadableDualEncoder_Reload(t *testing.T) {
+	var failSmokeTest bool
+	de, err := rocketqa.NewReloadableDualEncoder(&rocketqa.ReloadableDualEncoderConfig{
+		DualEncoderConfig: *newDualEncoderConfig(1),
+		SmokeTest: func(de *rocketqa.DualEncoder) error {
+			if failSmokeTest {
+				return errors.New("bad model")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldFingerprint := de.Fingerprint()
+
+	cfg := newDualEncoderConfig(1)
+	cfg.ParaMaxSeqLength = 128
+	if err := de.Reload(cfg); err != nil {
+		t.Fatal(err)
+	}
+	newFingerprint := de.Fingerprint()
+	if newFingerprint == oldFingerprint {
+		t.Errorf("Want a new fingerprint after reload")
+	}
+	if got := de.Config().ParaMaxSeqLength; got != 128 {
+		t.Errorf("ParaMaxSeqLength: Got (%v) != Want (128)", got)
+	}
+
+	// Failed reloads keep the current model.
+	failSmokeTest = true
+	if err := de.Reload(newDualEncoderConfig(1)); err == nil {
+		t.Errorf("Want error for failed smoke test")
+	}
+	failSmokeTest = false
+
+	cfg = newDualEncoderConfig(1)
+	cfg.ModelPath = "./testdata/not_exist.pdmodel"
+	err = de.Reload(cfg)
+	if !errors.Is(err, rocketqa.ErrModelLoad) {
+		t.Errorf("Got error (%v), want %v", err, rocketqa.ErrModelLoad)
+	}
+	if n := strings.Count(err.Error(), rocketqa.ErrModelLoad.Error()); n != 1 {
+		t.Errorf("Got error (%v), want one %q", err, rocketqa.ErrModelLoad)
+	}
+
+	if got := de.Fingerprint(); got != newFingerprint {
+		t.Errorf("Fingerprint: Got (%v) != Want (%v)", got, newFingerprint)
+	}
+}
+
+func TestReloadableDualEncoder_Concurrency(t *testing.T) {
+	de, err := rocketqa.NewReloadableDualEncoder(&rocketqa.ReloadableDualEncoderConfig{
+		DualEncoderConfig: *newDualEncoderConfig(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				vectors, err := de.EncodeQueries(context.Background(), []string{"你好，世界！"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(vectors) != 1 {
+					t.Errorf("got %d vectors, want 1", len(vectors))
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := de.Reload(newDualEncoderConfig(2)); err != nil {
+			t.Error(err)
+		}
+	}
+	cancel()
+	wg.Wait()
+}
+
+func TestReloadableDualEncoder_Close(t *testing.T) {
+	hooks := &blockingHooks{started: make(chan struct{}), release: make(chan struct{})}
+	cfg := newDualEncoderConfig(1)
+	cfg.Hooks = hooks
+	de, err := rocketqa.NewReloadableDualEncoder(&rocketqa.ReloadableDualEncoderConfig{
+		DualEncoderConfig: *cfg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start a request, which is blocked in the hooks before its inference.
+	inflight := make(chan error)
+	go func() {
+		ctx := rocketqa.WithRequestID(context.Background(), "block")
+		_, err := de.EncodeQueries(ctx, []string{"你好"})
+		inflight <- err
+	}()
+	<-hooks.started
+
+	closed := make(chan error)
+	go func() { closed <- de.Close() }()
+	time.Sleep(50 * time.Millisecond)
+	close(hooks.release)
+
+	// The in-flight request finishes before the model is closed.
+	if err := <-inflight; err != nil {
+		t.Errorf("in-flight request: got error %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := de.EncodeQueries(context.Background(), []string{"你好"}); !errors.Is(err, rocketqa.ErrClosed) {
+		t.Errorf("EncodeQueries: got error %v, want %v", err, rocketqa.ErrClosed)
+	}
+
+	// A later reload loads a new model.
+	if err := de.Reload(newDualEncoderConfig(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := de.EncodeQueries(context.Background(), []string{"你好"}); err != nil {
+		t.Errorf("EncodeQueries: got error %v", err)
+	}
+}
+
+// blockingHooks blocks the calls with the request ID "block" when they start,
+// until release is closed.
+type blockingHooks struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHooks) CallStarted(ctx context.Context, op rocketqa.Op) context.Context {
+	if rocketqa.RequestID(ctx) == "block" {
+		close(h.started)
+		<-h.release
+	}
+	return ctx
+}
+
+func (h *blockingHooks) CallFinished(ctx context.Context, stats rocketqa.CallStats) {}
+
+func TestReloadableCrossEncoder_Watch(t *testing.T) {
+	// Build a model directory from the test data.
+	dir := t.TempDir()
+	cfg := newCrossEncoderConfig(1)
+	for _, name := range []string{cfg.ModelPath, cfg.ParamsPath} {
+		abs, err := filepath.Abs(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(abs, filepath.Join(dir, filepath.Base(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vocab, err := os.ReadFile(cfg.VocabFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vocabFile := filepath.Join(dir, "vocab.txt")
+	if err := os.WriteFile(vocabFile, vocab, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ce, err := rocketqa.NewReloadableCrossEncoder(&rocketqa.ReloadableCrossEncoderConfig{
+		CrossEncoderConfig: *cfg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 1)
+	go ce.Watch(ctx, dir, 10*time.Millisecond, func(err error) {
+		reloaded <- err
+	})
+
+	// Let the watcher take its first snapshot, and then update the vocab.
+	time.Sleep(30 * time.Millisecond)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(vocabFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	if got := ce.Config().VocabFile; got != vocabFile {
+		t.Errorf("VocabFile: Got (%v) != Want (%v)", got, vocabFile)
+	}
+}
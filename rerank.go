@@ -0,0 +1,85 @@
+package rocketqa
+
+import (
+	"context"
+	"sort"
+)
+
+// Candidate is a paragraph to be reranked against a query.
+type Candidate struct {
+	// An optional ID, which is carried through to the result.
+	ID    string
+	Title string
+	Para  string
+	// Optional metadata of the caller, which is carried through along with
+	// ID. Note that comparing Candidates with == panics if Metadata holds a
+	// value that is not comparable, such as a map.
+	Metadata any
+}
+
+// RerankOptions controls the results of Rerank.
+type RerankOptions struct {
+	// The maximum number of results. Zero means no limit.
+	TopN int
+	// If not nil, the candidates whose scores are less than *MinScore are
+	// dropped.
+	MinScore *float32
+}
+
+// RerankResult is a candidate along with its score.
+type RerankResult struct {
+	Candidate
+	// The position of the candidate in the input.
+	Index int
+	Score float32
+}
+
+// Rerank scores the candidates against query, and returns them in
+// descending order of their scores. Candidates with equal scores keep their
+// input order. A nil opts means no limits.
+func (ce *CrossEncoder) Rerank(ctx context.Context, query string, candidates []Candidate, opts *RerankOptions) ([]RerankResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	paras := make([]string, len(candidates))
+	titles := make([]string, len(candidates))
+	for i, c := range candidates {
+		paras[i], titles[i] = c.Para, c.Title
+	}
+
+	scores, err := ce.RankQueryContext(ctx, query, paras, titles)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortResults(candidates, scores, opts), nil
+}
+
+// sortResults zips the candidates with their scores, and sorts and filters
+// them according to opts.
+func sortResults(candidates []Candidate, scores []float32, opts *RerankOptions) []RerankResult {
+	if opts == nil {
+		opts = &RerankOptions{}
+	}
+
+	results := make([]RerankResult, 0, len(candidates))
+	for i, c := range candidates {
+		if opts.MinScore != nil && scores[i] < *opts.MinScore {
+			continue
+		}
+		results = append(results, RerankResult{Candidate: c, Index: i, Score: scores[i]})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if opts.TopN > 0 && len(results) > opts.TopN {
+		results = results[:opts.TopN]
+	}
+	return results
+}
@@ -0,0 +1,83 @@
+package rocketqa_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrossEncoder_Rerank(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := "你好，世界！"
+	candidates := []rocketqa.Candidate{
+		{ID: "a", Para: "这是一段较长的文本。"},
+		{ID: "b", Para: "This is a long paragraph."},
+		{ID: "c", Para: "你好，世界！"},
+		{ID: "d", Para: "这是一段较长的文本。"},
+	}
+
+	// Compute the expected results from Rank.
+	var qpts rocketqa.QPTs
+	for _, c := range candidates {
+		qpts = append(qpts, rocketqa.QPT{Query: query, Para: c.Para, Title: c.Title})
+	}
+	scores, err := ce.Rank(qpts.Q(), qpts.P(), qpts.T())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []rocketqa.RerankResult
+	for i, c := range candidates {
+		all = append(all, rocketqa.RerankResult{Candidate: c, Index: i, Score: scores[i]})
+	}
+	// Candidates "a" and "d" have equal scores, thus "a" must come first.
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Score > all[j].Score })
+
+	minScore := all[1].Score
+	var aboveMin []rocketqa.RerankResult
+	for _, r := range all {
+		if r.Score >= minScore {
+			aboveMin = append(aboveMin, r)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		inOpts      *rocketqa.RerankOptions
+		wantResults []rocketqa.RerankResult
+	}{
+		{
+			name:        "no options",
+			inOpts:      nil,
+			wantResults: all,
+		},
+		{
+			name:        "top n",
+			inOpts:      &rocketqa.RerankOptions{TopN: 3},
+			wantResults: all[:3],
+		},
+		{
+			name:        "min score",
+			inOpts:      &rocketqa.RerankOptions{MinScore: &minScore},
+			wantResults: aboveMin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotResults, err := ce.Rerank(context.Background(), query, candidates, tt.inOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(gotResults, tt.wantResults) {
+				diff := cmp.Diff(gotResults, tt.wantResults)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package retrieval
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type BM25Config struct {
+	// The term frequency saturation parameter. Defaults to 1.2.
+	K1 float64
+	// If not nil, the document length normalization parameter, where zero
+	// means no normalization. Defaults to 0.75.
+	B *float64
+	// The function that splits text into terms. Defaults to Analyze.
+	Analyzer func(text string) []string
+}
+
+// BM25Index is an in-memory lexical index that ranks documents by Okapi
+// BM25 over their titles and paragraphs.
+type BM25Index struct {
+	mu sync.RWMutex
+
+	k1, b    float64
+	analyzer func(text string) []string
+
+	docs     []Document
+	docLens  []int
+	totalLen int
+	postings map[string][]posting
+}
+
+type posting struct {
+	doc int
+	tf  int
+}
+
+func NewBM25Index(cfg *BM25Config) *BM25Index {
+	idx := &BM25Index{
+		k1:       1.2,
+		b:        0.75,
+		analyzer: Analyze,
+		postings: make(map[string][]posting),
+	}
+	if cfg != nil {
+		if cfg.K1 > 0 {
+			idx.k1 = cfg.K1
+		}
+		if cfg.B != nil {
+			idx.b = *cfg.B
+		}
+		if cfg.Analyzer != nil {
+			idx.analyzer = cfg.Analyzer
+		}
+	}
+	return idx
+}
+
+// Add adds the documents to the index.
+func (idx *BM25Index) Add(docs ...Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, doc := range docs {
+		terms := idx.analyzer(doc.Title + " " + doc.Para)
+		tfs := make(map[string]int)
+		for _, t := range terms {
+			tfs[t]++
+		}
+
+		n := len(idx.docs)
+		for t, tf := range tfs {
+			idx.postings[t] = append(idx.postings[t], posting{doc: n, tf: tf})
+		}
+		idx.docs = append(idx.docs, doc)
+		idx.docLens = append(idx.docLens, len(terms))
+		idx.totalLen += len(terms)
+	}
+}
+
+// Len returns the number of documents in the index.
+func (idx *BM25Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Retrieve returns the k documents with the highest BM25 scores for query.
+// Documents sharing no term with query are never returned.
+func (idx *BM25Index) Retrieve(ctx context.Context, query string, k int) ([]Hit, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := len(idx.docs)
+	if n == 0 {
+		return nil, nil
+	}
+	avgLen := float64(idx.totalLen) / float64(n)
+
+	scores := make(map[int]float64)
+	for _, t := range idx.analyzer(query) {
+		postings := idx.postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (float64(n)-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := idx.k1 * (1 - idx.b + idx.b*float64(idx.docLens[p.doc])/avgLen)
+			scores[p.doc] += idf * tf * (idx.k1 + 1) / (tf + norm)
+		}
+	}
+
+	docs := make([]int, 0, len(scores))
+	for doc := range scores {
+		docs = append(docs, doc)
+	}
+	// Break ties by the insertion order to make the results deterministic.
+	sort.Ints(docs)
+
+	hits := make([]Hit, len(docs))
+	for i, doc := range docs {
+		hits[i] = Hit{Document: idx.docs[doc], Score: scores[doc]}
+	}
+	sortHits(hits)
+	return truncate(hits, k), nil
+}
+
+// Analyze splits text into lowercase terms. Every Chinese character is a
+// term by itself, and any other term is a run of letters or digits.
+func Analyze(text string) []string {
+	var terms []string
+	var b strings.Builder
+
+	flush := func() {
+		if b.Len() > 0 {
+			terms = append(terms, b.String())
+			b.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return terms
+}
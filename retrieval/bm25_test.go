@@ -0,0 +1,134 @@
+package retrieval_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		inText    string
+		wantTerms []string
+	}{
+		{
+			inText:    "你好，世界！",
+			wantTerms: []string{"你", "好", "世", "界"},
+		},
+		{
+			inText:    "Hello, World! RocketQA v2",
+			wantTerms: []string{"hello", "world", "rocketqa", "v2"},
+		},
+		{
+			inText:    "Go语言",
+			wantTerms: []string{"go", "语", "言"},
+		},
+	}
+	for _, tt := range tests {
+		gotTerms := retrieval.Analyze(tt.inText)
+		if !cmp.Equal(gotTerms, tt.wantTerms) {
+			diff := cmp.Diff(gotTerms, tt.wantTerms)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+func TestBM25Index_B(t *testing.T) {
+	docs := []retrieval.Document{
+		{ID: "short", Para: "go"},
+		{ID: "long", Para: "go is an open source programming language"},
+	}
+	b := 0.0
+
+	tests := []struct {
+		name      string
+		inCfg     *retrieval.BM25Config
+		wantEqual bool
+	}{
+		{
+			name:      "default",
+			inCfg:     nil,
+			wantEqual: false,
+		},
+		{
+			name:      "no length normalization",
+			inCfg:     &retrieval.BM25Config{B: &b},
+			wantEqual: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := retrieval.NewBM25Index(tt.inCfg)
+			idx.Add(docs...)
+
+			hits, err := idx.Retrieve(context.Background(), "go", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hits) != 2 {
+				t.Fatalf("Got %d hits, want 2", len(hits))
+			}
+			if gotEqual := hits[0].Score == hits[1].Score; gotEqual != tt.wantEqual {
+				t.Errorf("Got scores %v and %v, want equal: %v", hits[0].Score, hits[1].Score, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestBM25Index_Retrieve(t *testing.T) {
+	idx := retrieval.NewBM25Index(nil)
+	idx.Add(
+		retrieval.Document{ID: "1", Title: "Go", Para: "Go is an open source programming language."},
+		retrieval.Document{ID: "2", Title: "Python", Para: "Python is a programming language that lets you work quickly."},
+		retrieval.Document{ID: "3", Title: "Cooking", Para: "A recipe for noodles."},
+		retrieval.Document{ID: "4", Title: "长城", Para: "长城是古代中国的军事防御工程。"},
+	)
+
+	tests := []struct {
+		inQuery string
+		inK     int
+		wantIDs []string
+	}{
+		{
+			inQuery: "go programming",
+			inK:     10,
+			wantIDs: []string{"1", "2"},
+		},
+		{
+			inQuery: "programming language",
+			inK:     1,
+			wantIDs: []string{"1"},
+		},
+		{
+			inQuery: "中国长城",
+			inK:     10,
+			wantIDs: []string{"4"},
+		},
+		{
+			inQuery: "nothing matches",
+			inK:     10,
+			wantIDs: nil,
+		},
+	}
+	for _, tt := range tests {
+		hits, err := idx.Retrieve(context.Background(), tt.inQuery, tt.inK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotIDs := hitIDs(hits)
+		if !cmp.Equal(gotIDs, tt.wantIDs) {
+			diff := cmp.Diff(gotIDs, tt.wantIDs)
+			t.Errorf("%q: Want - Got: %s", tt.inQuery, diff)
+		}
+	}
+}
+
+func hitIDs(hits []retrieval.Hit) []string {
+	var ids []string
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
@@ -0,0 +1,96 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+// Encoder encodes queries and paragraphs into vectors. It is implemented by
+// *rocketqa.DualEncoder, *rocketqa.CachedDualEncoder and
+// *rocketqa.ReloadableDualEncoder.
+type Encoder interface {
+	EncodeQueries(ctx context.Context, queries []string) ([]vecmath.Vector, error)
+	EncodePara(paras, titles []string) ([]vecmath.Vector, error)
+}
+
+// DenseIndex is an in-memory dense index that ranks documents by the dot
+// product between their vectors and the query vector, by exhaustive search.
+//
+// Use an encoder that outputs normalized vectors to rank by cosine
+// similarity.
+type DenseIndex struct {
+	mu sync.RWMutex
+
+	encoder Encoder
+	docs    []Document
+	vectors []vecmath.Vector
+}
+
+func NewDenseIndex(encoder Encoder) *DenseIndex {
+	return &DenseIndex{encoder: encoder}
+}
+
+// Add encodes the documents and adds them to the index.
+func (idx *DenseIndex) Add(docs ...Document) error {
+	paras := make([]string, len(docs))
+	titles := make([]string, len(docs))
+	for i, doc := range docs {
+		paras[i], titles[i] = doc.Para, doc.Title
+	}
+
+	vectors, err := idx.encoder.EncodePara(paras, titles)
+	if err != nil {
+		return err
+	}
+	return idx.AddEncoded(docs, vectors)
+}
+
+// AddEncoded adds the documents along with their precomputed vectors.
+func (idx *DenseIndex) AddEncoded(docs []Document, vectors []vecmath.Vector) error {
+	if len(vectors) != len(docs) {
+		return errors.New("len(vectors) does not equal len(docs)")
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.docs = append(idx.docs, docs...)
+	idx.vectors = append(idx.vectors, vectors...)
+	return nil
+}
+
+// Len returns the number of documents in the index.
+func (idx *DenseIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Retrieve encodes query and returns the k documents with the most similar
+// vectors.
+func (idx *DenseIndex) Retrieve(ctx context.Context, query string, k int) ([]Hit, error) {
+	vectors, err := idx.encoder.EncodeQueries(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, errors.New("failed to encode the query")
+	}
+	return idx.RetrieveByVector(vectors[0], k), nil
+}
+
+// RetrieveByVector returns the k documents with the vectors most similar to
+// vector.
+func (idx *DenseIndex) RetrieveByVector(vector vecmath.Vector, k int) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var hits []Hit
+	for _, m := range vecmath.TopKVectors(vector, idx.vectors, k) {
+		hits = append(hits, Hit{Document: idx.docs[m.Index], Score: float64(m.Score)})
+	}
+	return hits
+}
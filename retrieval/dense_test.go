@@ -0,0 +1,67 @@
+package retrieval_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDenseIndex_Retrieve(t *testing.T) {
+	idx := retrieval.NewDenseIndex(fakeEncoder{
+		"p1": {1, 0},
+		"p2": {0, 1},
+		"p3": {0.6, 0.8},
+		"q":  {0, 1},
+	})
+	err := idx.Add(
+		retrieval.Document{ID: "1", Para: "p1"},
+		retrieval.Document{ID: "2", Para: "p2"},
+		retrieval.Document{ID: "3", Para: "p3"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotHits, err := idx.Retrieve(context.Background(), "q", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHits := []retrieval.Hit{
+		{Document: retrieval.Document{ID: "2", Para: "p2"}, Score: 1},
+		{Document: retrieval.Document{ID: "3", Para: "p3"}, Score: float64(float32(0.8))},
+	}
+	if !cmp.Equal(gotHits, wantHits) {
+		diff := cmp.Diff(gotHits, wantHits)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	if _, err := idx.Retrieve(context.Background(), "unknown", 2); !errors.Is(err, errEncode) {
+		t.Errorf("got error %v, want %v", err, errEncode)
+	}
+}
+
+// fakeEncoder encodes texts by looking up a fixed table, and fails on the
+// texts missing from it.
+type fakeEncoder map[string]vecmath.Vector
+
+func (e fakeEncoder) EncodeQueries(ctx context.Context, queries []string) ([]vecmath.Vector, error) {
+	var vectors []vecmath.Vector
+	for _, q := range queries {
+		v, ok := e[q]
+		if !ok {
+			return nil, errEncode
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+func (e fakeEncoder) EncodePara(paras, titles []string) ([]vecmath.Vector, error) {
+	return e.EncodeQueries(context.Background(), paras)
+}
+
+var errEncode = errors.New("encode failed")
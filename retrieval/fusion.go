@@ -0,0 +1,104 @@
+package retrieval
+
+// Fusion combines several ranked lists of hits into one.
+type Fusion interface {
+	// Fuse merges the lists into one ranked list, where hits referring to
+	// the same document ID are combined. Hits without IDs are never
+	// combined, since they cannot be told apart.
+	Fuse(lists [][]Hit) []Hit
+}
+
+// RRF is reciprocal rank fusion, which scores every document by the sum of
+// 1 / (K + rank) over the lists containing it, with ranks starting at 1.
+//
+// It only uses the ranks, so it works well with retrievers whose scores are
+// on different scales, such as BM25 and dot product.
+type RRF struct {
+	// The rank constant that dampens the impact of top ranks. Defaults
+	// to 60.
+	K int
+}
+
+func (f RRF) Fuse(lists [][]Hit) []Hit {
+	k := f.K
+	if k <= 0 {
+		k = 60
+	}
+
+	m := newMerger()
+	for _, list := range lists {
+		for rank, h := range list {
+			m.add(h.Document, 1/float64(k+rank+1))
+		}
+	}
+	return m.hits()
+}
+
+// WeightedSum scores every document by the weighted sum of its min-max
+// normalized scores in the lists. A document missing from a list gets zero
+// from that list.
+type WeightedSum struct {
+	// The weight of each list, in the same order as the lists. Defaults to
+	// equal weights.
+	Weights []float64
+}
+
+func (f WeightedSum) Fuse(lists [][]Hit) []Hit {
+	m := newMerger()
+	for i, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+
+		w := 1.0
+		if i < len(f.Weights) {
+			w = f.Weights[i]
+		}
+
+		lo, hi := list[0].Score, list[0].Score
+		for _, h := range list {
+			if h.Score < lo {
+				lo = h.Score
+			}
+			if h.Score > hi {
+				hi = h.Score
+			}
+		}
+
+		for _, h := range list {
+			norm := 1.0
+			if hi > lo {
+				norm = (h.Score - lo) / (hi - lo)
+			}
+			m.add(h.Document, w*norm)
+		}
+	}
+	return m.hits()
+}
+
+// merger accumulates scores by document ID, in the order of first sight.
+// Documents without IDs are kept apart.
+type merger struct {
+	pos  map[string]int
+	list []Hit
+}
+
+func newMerger() *merger {
+	return &merger{pos: make(map[string]int)}
+}
+
+func (m *merger) add(doc Document, score float64) {
+	if doc.ID != "" {
+		if i, ok := m.pos[doc.ID]; ok {
+			m.list[i].Score += score
+			return
+		}
+		m.pos[doc.ID] = len(m.list)
+	}
+	m.list = append(m.list, Hit{Document: doc, Score: score})
+}
+
+func (m *merger) hits() []Hit {
+	sortHits(m.list)
+	return m.list
+}
@@ -0,0 +1,79 @@
+package retrieval_test
+
+import (
+	"testing"
+
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFusion(t *testing.T) {
+	lexical := []retrieval.Hit{
+		newHit("a", 12),
+		newHit("b", 8),
+		newHit("c", 2),
+	}
+	dense := []retrieval.Hit{
+		newHit("c", 0.9),
+		newHit("a", 0.8),
+		newHit("d", 0.7),
+	}
+
+	tests := []struct {
+		name     string
+		inFusion retrieval.Fusion
+		inLists  [][]retrieval.Hit
+		wantHits []retrieval.Hit
+	}{
+		{
+			name:     "rrf",
+			inFusion: retrieval.RRF{K: 1},
+			inLists:  [][]retrieval.Hit{lexical, dense},
+			wantHits: []retrieval.Hit{
+				newHit("a", 1.0/2+1.0/3),
+				newHit("c", 1.0/4+1.0/2),
+				newHit("b", 1.0/3),
+				newHit("d", 1.0/4),
+			},
+		},
+		{
+			name:     "rrf without ids",
+			inFusion: retrieval.RRF{K: 1},
+			inLists: [][]retrieval.Hit{
+				{newHit("", 2), newHit("a", 1)},
+				{newHit("", 2)},
+			},
+			wantHits: []retrieval.Hit{
+				newHit("", 1.0/2),
+				newHit("", 1.0/2),
+				newHit("a", 1.0/3),
+			},
+		},
+		{
+			name:     "weighted",
+			inFusion: retrieval.WeightedSum{Weights: []float64{0.3, 0.7}},
+			inLists:  [][]retrieval.Hit{lexical, dense},
+			wantHits: []retrieval.Hit{
+				newHit("c", 0.3*0+0.7*1),
+				newHit("a", 0.3*1+0.7*0.5),
+				newHit("b", 0.3*0.6),
+				newHit("d", 0.7*0),
+			},
+		},
+	}
+	opt := cmpopts.EquateApprox(0, 1e-9)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHits := tt.inFusion.Fuse(tt.inLists)
+			if !cmp.Equal(gotHits, tt.wantHits, opt) {
+				diff := cmp.Diff(gotHits, tt.wantHits)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func newHit(id string, score float64) retrieval.Hit {
+	return retrieval.Hit{Document: retrieval.Document{ID: id}, Score: score}
+}
@@ -0,0 +1,44 @@
+package retrieval
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Hybrid is a retriever that runs several retrievers concurrently, typically
+// a lexical one and a dense one, and combines their results by Fusion.
+type Hybrid struct {
+	Retrievers []Retriever
+	// The fusion method. Defaults to RRF.
+	Fusion Fusion
+	// The number of hits to request from each retriever. Defaults to the
+	// number of requested hits.
+	Depth int
+}
+
+func (h *Hybrid) Retrieve(ctx context.Context, query string, k int) ([]Hit, error) {
+	depth := h.Depth
+	if depth < k {
+		depth = k
+	}
+
+	lists := make([][]Hit, len(h.Retrievers))
+	g, ctx := errgroup.WithContext(ctx)
+	for i, r := range h.Retrievers {
+		i, r := i, r
+		g.Go(func() (err error) {
+			lists[i], err = r.Retrieve(ctx, query, depth)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	fusion := h.Fusion
+	if fusion == nil {
+		fusion = RRF{}
+	}
+	return truncate(fusion.Fuse(lists), k), nil
+}
@@ -0,0 +1,64 @@
+package retrieval_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHybrid_Retrieve(t *testing.T) {
+	docs := []retrieval.Document{
+		{ID: "1", Para: "长城是古代中国的军事防御工程"},
+		{ID: "2", Para: "故宫是明清两代的皇家宫殿"},
+		{ID: "3", Para: "古代中国的建筑"},
+	}
+
+	lexical := retrieval.NewBM25Index(nil)
+	lexical.Add(docs...)
+
+	dense := retrieval.NewDenseIndex(fakeEncoder{
+		"长城是古代中国的军事防御工程": {1, 0},
+		"故宫是明清两代的皇家宫殿":   {0, 1},
+		"古代中国的建筑":        {0.6, 0.8},
+		"皇家宫殿":           {0.1, 0.9},
+	})
+	if err := dense.Add(docs...); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &retrieval.Hybrid{
+		Retrievers: []retrieval.Retriever{lexical, dense},
+		Depth:      3,
+	}
+	hits, err := h.Retrieve(context.Background(), "皇家宫殿", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "2" is the best in both lists, and "3" is the second best in the
+	// dense list, while being absent in the lexical one.
+	gotIDs := hitIDs(hits)
+	wantIDs := []string{"2", "3"}
+	if !cmp.Equal(gotIDs, wantIDs) {
+		diff := cmp.Diff(gotIDs, wantIDs)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestHybrid_Retrieve_Error(t *testing.T) {
+	wantErr := errors.New("unavailable")
+	h := &retrieval.Hybrid{
+		Retrievers: []retrieval.Retriever{
+			retrieval.NewBM25Index(nil),
+			retrieval.RetrieverFunc(func(context.Context, string, int) ([]retrieval.Hit, error) {
+				return nil, wantErr
+			}),
+		},
+	}
+	if _, err := h.Retrieve(context.Background(), "query", 1); !errors.Is(err, wantErr) {
+		t.Errorf("Got error (%v), want (%v)", err, wantErr)
+	}
+}
@@ -0,0 +1,76 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ranker scores paragraphs (and their optional titles) against a query. It
+// is implemented by *rocketqa.CrossEncoder.
+type Ranker interface {
+	RankQueryContext(ctx context.Context, query string, paras, titles []string) ([]float32, error)
+}
+
+// Rerank scores the hits against query by ranker, and returns them in
+// descending order of their new scores. Hits with equal scores keep their
+// original order.
+func Rerank(ctx context.Context, ranker Ranker, query string, hits []Hit) ([]Hit, error) {
+	if len(hits) == 0 {
+		return hits, nil
+	}
+
+	paras := make([]string, len(hits))
+	titles := make([]string, len(hits))
+	for i, h := range hits {
+		paras[i], titles[i] = h.Para, h.Title
+	}
+
+	scores, err := ranker.RankQueryContext(ctx, query, paras, titles)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(hits) {
+		return nil, fmt.Errorf("got %d scores, want %d", len(scores), len(hits))
+	}
+
+	reranked := make([]Hit, len(hits))
+	for i, h := range hits {
+		reranked[i] = Hit{Document: h.Document, Score: float64(scores[i])}
+	}
+	sortHits(reranked)
+	return reranked, nil
+}
+
+// Pipeline retrieves candidates by Retriever, and then reranks them by
+// Ranker.
+type Pipeline struct {
+	Retriever Retriever
+	// The optional ranker. If nil, the retrieved hits are returned as is.
+	Ranker Ranker
+	// The number of candidates to retrieve for reranking. Defaults to the
+	// number of requested hits.
+	Depth int
+}
+
+// Search returns the k best documents for query, in descending order of
+// their ranking scores.
+func (p *Pipeline) Search(ctx context.Context, query string, k int) ([]Hit, error) {
+	depth := p.Depth
+	if depth < k {
+		depth = k
+	}
+
+	hits, err := p.Retriever.Retrieve(ctx, query, depth)
+	if err != nil {
+		return nil, err
+	}
+	if p.Ranker == nil {
+		return truncate(hits, k), nil
+	}
+
+	reranked, err := Rerank(ctx, p.Ranker, query, hits)
+	if err != nil {
+		return nil, err
+	}
+	return truncate(reranked, k), nil
+}
@@ -0,0 +1,78 @@
+package retrieval_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/retrieval/retrievaltest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPipeline_Search(t *testing.T) {
+	idx := retrieval.NewBM25Index(nil)
+	idx.Add(
+		retrieval.Document{ID: "1", Para: "go go go"},
+		retrieval.Document{ID: "2", Para: "go is fun"},
+		retrieval.Document{ID: "3", Para: "learn go today"},
+	)
+
+	p := &retrieval.Pipeline{
+		Retriever: idx,
+		Ranker: retrievaltest.Ranker{
+			"go go go":       0.1,
+			"go is fun":      0.5,
+			"learn go today": 0.9,
+		},
+		Depth: 3,
+	}
+	gotHits, err := p.Search(context.Background(), "go", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHits := []retrieval.Hit{
+		{Document: retrieval.Document{ID: "3", Para: "learn go today"}, Score: float64(float32(0.9))},
+		{Document: retrieval.Document{ID: "2", Para: "go is fun"}, Score: float64(float32(0.5))},
+	}
+	if !cmp.Equal(gotHits, wantHits) {
+		diff := cmp.Diff(gotHits, wantHits)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestRerank(t *testing.T) {
+	hits := []retrieval.Hit{
+		{Document: retrieval.Document{ID: "1", Para: "go go go"}, Score: 3},
+		{Document: retrieval.Document{ID: "2", Para: "go is fun"}, Score: 2},
+		{Document: retrieval.Document{ID: "3", Para: "learn go today"}, Score: 1},
+	}
+	ranker := retrievaltest.Ranker{
+		"go go go":       0.1,
+		"go is fun":      0.5,
+		"learn go today": 0.5,
+	}
+
+	gotHits, err := retrieval.Rerank(context.Background(), ranker, "go", hits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Hits with equal scores keep their original order.
+	wantHits := []retrieval.Hit{
+		{Document: retrieval.Document{ID: "2", Para: "go is fun"}, Score: float64(float32(0.5))},
+		{Document: retrieval.Document{ID: "3", Para: "learn go today"}, Score: float64(float32(0.5))},
+		{Document: retrieval.Document{ID: "1", Para: "go go go"}, Score: float64(float32(0.1))},
+	}
+	if !cmp.Equal(gotHits, wantHits) {
+		diff := cmp.Diff(gotHits, wantHits)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// The context is passed to the ranker.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := retrieval.Rerank(ctx, ranker, "go", hits); err != context.Canceled {
+		t.Errorf("Got error (%v), want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,52 @@
+// Package retrieval provides the building blocks of a retrieve-then-rerank
+// pipeline: in-memory lexical (BM25) and dense indexes, hybrid retrieval
+// with score fusion, and reranking by the cross encoder.
+package retrieval
+
+import (
+	"context"
+	"sort"
+)
+
+// Document is a paragraph that can be retrieved.
+type Document struct {
+	ID    string
+	Title string
+	Para  string
+}
+
+// Hit is a retrieved document along with its score. A higher score means a
+// better match, but scores from different retrievers are not comparable.
+type Hit struct {
+	Document
+	Score float64
+}
+
+// Retriever retrieves the k documents that best match query, best first.
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, k int) ([]Hit, error)
+}
+
+// RetrieverFunc is an adapter to allow the use of ordinary functions as
+// retrievers.
+type RetrieverFunc func(ctx context.Context, query string, k int) ([]Hit, error)
+
+func (f RetrieverFunc) Retrieve(ctx context.Context, query string, k int) ([]Hit, error) {
+	return f(ctx, query, k)
+}
+
+// sortHits sorts hits in descending order of score. The original order is
+// kept among hits with equal scores.
+func sortHits(hits []Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+}
+
+// truncate returns the first k hits, or all of them if there are fewer.
+func truncate(hits []Hit, k int) []Hit {
+	if k >= 0 && len(hits) > k {
+		return hits[:k]
+	}
+	return hits
+}
@@ -0,0 +1,44 @@
+// Package retrievaltest provides fakes of the encoders and rankers, for
+// testing the packages built on package retrieval without the models.
+package retrievaltest
+
+import (
+	"context"
+
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+// Encoder is a retrieval.Encoder that encodes texts by looking up a fixed
+// table. The texts missing from the table are encoded into nil vectors.
+type Encoder map[string]vecmath.Vector
+
+func (e Encoder) EncodeQueries(ctx context.Context, queries []string) ([]vecmath.Vector, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var vectors []vecmath.Vector
+	for _, q := range queries {
+		vectors = append(vectors, e[q])
+	}
+	return vectors, nil
+}
+
+func (e Encoder) EncodePara(paras, titles []string) ([]vecmath.Vector, error) {
+	return e.EncodeQueries(context.Background(), paras)
+}
+
+// Ranker is a retrieval.Ranker that scores paragraphs by looking up a fixed
+// table, whatever the query. The paragraphs missing from the table are
+// scored 0.
+type Ranker map[string]float32
+
+func (r Ranker) RankQueryContext(ctx context.Context, query string, paras, titles []string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var scores []float32
+	for _, p := range paras {
+		scores = append(scores, r[p])
+	}
+	return scores, nil
+}
@@ -0,0 +1,473 @@
+// Package elasticsearch stores the paragraph embeddings produced by the dual
+// encoder in Elasticsearch, and retrieves them by kNN search.
+//
+// The package does not depend on the encoders (and thus cgo), which are
+// accepted through small interfaces such as ParaEncoder and QueryEncoder.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/go-aie/rocketqa/fingerprint"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/vecmath"
+)
+
+type Config struct {
+	// The configuration of the Elasticsearch client, including the
+	// addresses, the credentials and the TLS settings.
+	Client elasticsearch.Config
+	// The name of the index.
+	Index string
+	// The dimension of the vectors. Defaults to 768.
+	Dims int
+	// The similarity used by kNN search, which is one of "dot_product",
+	// "cosine" and "l2_norm". Defaults to "dot_product", which requires
+	// normalized vectors (see DualEncoderConfig.Normalize).
+	Similarity string
+	// The number of candidates to consider per shard in kNN search, which
+	// is raised to the number of requested hits if it is smaller, as
+	// required by Elasticsearch. Defaults to 10 times the number of
+	// requested hits.
+	NumCandidates int
+	// The refresh policy of bulk upserts, which is one of "true", "false"
+	// and "wait_for". Defaults to the server-side default.
+	Refresh string
+	// The fingerprint of the dual encoder whose vectors are stored (see
+	// rocketqa.DualEncoder.Fingerprint). If set, it is recorded in the index
+	// mapping by CreateIndex, Upsert and Search refuse indexes with other
+	// fingerprints, and the methods taking an encoder refuse encoders with
+	// other fingerprints. Optional.
+	Fingerprint string
+}
+
+// fingerprinter is implemented by the encoders that have fingerprints, such as
+// *rocketqa.DualEncoder.
+type fingerprinter interface {
+	Fingerprint() string
+}
+
+// ParaEncoder encodes paragraphs into vectors. It is implemented by
+// *rocketqa.DualEncoder.
+type ParaEncoder interface {
+	EncodePara(paras, titles []string) ([]vecmath.Vector, error)
+}
+
+// QueryEncoder encodes queries into vectors, and is passed the context of the
+// store methods (e.g. carrying a request ID for logging). It is implemented
+// by *rocketqa.DualEncoder.
+type QueryEncoder interface {
+	EncodeQueries(ctx context.Context, queries []string) ([]vecmath.Vector, error)
+}
+
+// contextParaEncoder is implemented by the encoders that accept a context,
+// such as *rocketqa.DualEncoder, which are passed the context of the store
+// methods.
+type contextParaEncoder interface {
+	EncodeParaContext(ctx context.Context, paras, titles []string) ([]vecmath.Vector, error)
+}
+
+// Store is a vector store backed by an Elasticsearch index.
+type Store struct {
+	client        *elasticsearch.Client
+	index         string
+	mapping       IndexMapping
+	numCandidates int
+	refresh       string
+	fingerprint   string
+
+	// checked tells whether the fingerprint of the index has been checked
+	// successfully.
+	mu      sync.Mutex
+	checked bool
+}
+
+func New(cfg *Config) (*Store, error) {
+	if cfg.Index == "" {
+		return nil, errors.New("index is required")
+	}
+
+	dims := cfg.Dims
+	if dims == 0 {
+		dims = 768
+	}
+	similarity := cfg.Similarity
+	if similarity == "" {
+		similarity = "dot_product"
+	}
+
+	client, err := elasticsearch.NewClient(cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := NewIndexMapping(dims, similarity)
+	if cfg.Fingerprint != "" {
+		mapping.Mappings.Meta = map[string]any{fingerprintMetaKey: cfg.Fingerprint}
+	}
+
+	return &Store{
+		client:        client,
+		index:         cfg.Index,
+		mapping:       mapping,
+		numCandidates: cfg.NumCandidates,
+		refresh:       cfg.Refresh,
+		fingerprint:   cfg.Fingerprint,
+	}, nil
+}
+
+// CreateIndex creates the index with the mapping of s.
+func (s *Store) CreateIndex(ctx context.Context) error {
+	body, err := json.Marshal(s.mapping)
+	if err != nil {
+		return err
+	}
+
+	res, err := esapi.IndicesCreateRequest{
+		Index: s.index,
+		Body:  bytes.NewReader(body),
+	}.Do(ctx, s.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// CheckFingerprint returns an error wrapping fingerprint.ErrMismatch (i.e.
+// rocketqa.ErrFingerprintMismatch) if the fingerprint recorded in the
+// existing index differs from Config.Fingerprint. Indexes without
+// fingerprints are assumed to match.
+//
+// If Config.Fingerprint is set, Upsert and Search call it on their first use
+// of the index, until it succeeds.
+func (s *Store) CheckFingerprint(ctx context.Context) error {
+	res, err := esapi.IndicesGetMappingRequest{
+		Index: []string{s.index},
+	}.Do(ctx, s.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+
+	// The response is keyed by the concrete index name, which may differ
+	// from s.index if it is an alias.
+	var resp map[string]IndexMapping
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+	for _, m := range resp {
+		got, _ := m.Mappings.Meta[fingerprintMetaKey].(string)
+		if got != "" && got != s.fingerprint {
+			return fmt.Errorf("%w: index has %q, store has %q", fingerprint.ErrMismatch, got, s.fingerprint)
+		}
+	}
+	return nil
+}
+
+// checkIndex calls CheckFingerprint if Config.Fingerprint is set and the
+// index has not been checked yet. A missing index is not an error, since it
+// has no vectors to mix with.
+func (s *Store) checkIndex(ctx context.Context) error {
+	if s.fingerprint == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.checked {
+		return nil
+	}
+	err := s.CheckFingerprint(ctx)
+	var respErr *ResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.checked = true
+	return nil
+}
+
+// checkEncoder returns an error wrapping fingerprint.ErrMismatch if enc
+// has a fingerprint other than Config.Fingerprint.
+func (s *Store) checkEncoder(enc any) error {
+	f, ok := enc.(fingerprinter)
+	if !ok || s.fingerprint == "" {
+		return nil
+	}
+	if got := f.Fingerprint(); got != s.fingerprint {
+		return fmt.Errorf("%w: store has %q, encoder has %q", fingerprint.ErrMismatch, s.fingerprint, got)
+	}
+	return nil
+}
+
+// Upsert indexes the documents in one bulk request, replacing any existing
+// documents with the same IDs. Documents without IDs get auto-generated ones.
+//
+// If some of the documents fail, Upsert returns a *BulkError.
+func (s *Store) Upsert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := s.checkIndex(ctx); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i, doc := range docs {
+		if len(doc.Vector) != s.mapping.Dims() {
+			return fmt.Errorf("docs[%d]: got vector dimension %d, want %d", i, len(doc.Vector), s.mapping.Dims())
+		}
+
+		action := bulkAction{Index: bulkMeta{ID: doc.ID}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	res, err := esapi.BulkRequest{
+		Index:   s.index,
+		Body:    &buf,
+		Refresh: s.refresh,
+	}.Do(ctx, s.client)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return err
+	}
+
+	var resp bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return err
+	}
+	if !resp.Errors {
+		return nil
+	}
+
+	bulkErr := new(BulkError)
+	for i, item := range resp.Items {
+		if item.Index.Error != nil {
+			bulkErr.Failures = append(bulkErr.Failures, BulkFailure{
+				Index:  i,
+				ID:     item.Index.ID,
+				Status: item.Index.Status,
+				Type:   item.Index.Error.Type,
+				Reason: item.Index.Error.Reason,
+			})
+		}
+	}
+	return bulkErr
+}
+
+// EncodeAndUpsert encodes the paragraphs of docs by using enc, and then
+// upserts the documents along with their vectors. If enc has a fingerprint,
+// it must equal Config.Fingerprint.
+func (s *Store) EncodeAndUpsert(ctx context.Context, enc ParaEncoder, docs []Document) error {
+	if err := s.checkEncoder(enc); err != nil {
+		return err
+	}
+
+	paras := make([]string, len(docs))
+	titles := make([]string, len(docs))
+	for i, doc := range docs {
+		paras[i], titles[i] = doc.Paragraph, doc.Title
+	}
+
+	var vectors []vecmath.Vector
+	var err error
+	if e, ok := enc.(contextParaEncoder); ok {
+		vectors, err = e.EncodeParaContext(ctx, paras, titles)
+	} else {
+		vectors, err = enc.EncodePara(paras, titles)
+	}
+	if err != nil {
+		return err
+	}
+	if len(vectors) != len(docs) {
+		return fmt.Errorf("got %d vectors, want %d", len(vectors), len(docs))
+	}
+
+	encoded := make([]Document, len(docs))
+	for i, doc := range docs {
+		doc.Vector = vectors[i]
+		encoded[i] = doc
+	}
+	return s.Upsert(ctx, encoded)
+}
+
+// Search returns the k documents whose vectors are the most similar to
+// vector, best first.
+func (s *Store) Search(ctx context.Context, vector []float32, k int) ([]Hit, error) {
+	if err := s.checkIndex(ctx); err != nil {
+		return nil, err
+	}
+
+	numCandidates := s.numCandidates
+	if numCandidates == 0 {
+		numCandidates = 10 * k
+	} else if numCandidates < k {
+		numCandidates = k
+	}
+
+	body, err := json.Marshal(searchRequest{
+		Knn: &knnQuery{
+			Field:         vectorField,
+			QueryVector:   vector,
+			K:             k,
+			NumCandidates: numCandidates,
+		},
+		Source: []string{titleField, paragraphField},
+		Size:   k,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.search(ctx, body)
+}
+
+// SearchText returns the k documents that best match query by full-text
+// search (BM25) over the title and the paragraph.
+func (s *Store) SearchText(ctx context.Context, query string, k int) ([]Hit, error) {
+	body, err := json.Marshal(searchRequest{
+		Query: &textQuery{
+			MultiMatch: multiMatch{
+				Query:  query,
+				Fields: []string{titleField, paragraphField},
+			},
+		},
+		Source: []string{titleField, paragraphField},
+		Size:   k,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.search(ctx, body)
+}
+
+// EncodeAndSearch encodes query by using enc, and then searches for the k
+// most similar documents. If enc has a fingerprint, it must equal
+// Config.Fingerprint.
+func (s *Store) EncodeAndSearch(ctx context.Context, enc QueryEncoder, query string, k int) ([]Hit, error) {
+	if err := s.checkEncoder(enc); err != nil {
+		return nil, err
+	}
+
+	vectors, err := enc.EncodeQueries(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("got %d query vectors, want 1", len(vectors))
+	}
+	return s.Search(ctx, vectors[0], k)
+}
+
+// LexicalRetriever returns a retriever backed by SearchText.
+func (s *Store) LexicalRetriever() retrieval.Retriever {
+	return retrieval.RetrieverFunc(func(ctx context.Context, query string, k int) ([]retrieval.Hit, error) {
+		hits, err := s.SearchText(ctx, query, k)
+		return toRetrievalHits(hits), err
+	})
+}
+
+// DenseRetriever returns a retriever backed by EncodeAndSearch.
+func (s *Store) DenseRetriever(enc QueryEncoder) retrieval.Retriever {
+	return retrieval.RetrieverFunc(func(ctx context.Context, query string, k int) ([]retrieval.Hit, error) {
+		hits, err := s.EncodeAndSearch(ctx, enc, query, k)
+		return toRetrievalHits(hits), err
+	})
+}
+
+func toRetrievalHits(hits []Hit) []retrieval.Hit {
+	var result []retrieval.Hit
+	for _, h := range hits {
+		result = append(result, retrieval.Hit{
+			Document: retrieval.Document{
+				ID:    h.ID,
+				Title: h.Title,
+				Para:  h.Paragraph,
+			},
+			Score: h.Score,
+		})
+	}
+	return result
+}
+
+func (s *Store) search(ctx context.Context, body []byte) ([]Hit, error) {
+	res, err := esapi.SearchRequest{
+		Index: []string{s.index},
+		Body:  bytes.NewReader(body),
+	}.Do(ctx, s.client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if err := checkResponse(res); err != nil {
+		return nil, err
+	}
+
+	var resp searchResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(resp.Hits.Hits))
+	for i, h := range resp.Hits.Hits {
+		hits[i] = Hit{
+			ID:        h.ID,
+			Score:     h.Score,
+			Title:     h.Source.Title,
+			Paragraph: h.Source.Paragraph,
+		}
+	}
+	return hits, nil
+}
+
+// checkResponse returns a *ResponseError if res indicates an error.
+func checkResponse(res *esapi.Response) error {
+	if !res.IsError() {
+		return nil
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	respErr := &ResponseError{StatusCode: res.StatusCode}
+	var e errorResponse
+	if json.Unmarshal(b, &e) == nil && e.Error.Type != "" {
+		respErr.Type, respErr.Reason = e.Error.Type, e.Error.Reason
+	} else {
+		respErr.Reason = strings.TrimSpace(string(b))
+	}
+	return respErr
+}
@@ -0,0 +1,580 @@
+package elasticsearch_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	es "github.com/elastic/go-elasticsearch/v8"
+	"github.com/go-aie/rocketqa/fingerprint"
+	"github.com/go-aie/rocketqa/retrieval"
+	"github.com/go-aie/rocketqa/retrieval/retrievaltest"
+	"github.com/go-aie/rocketqa/store/elasticsearch"
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStore_CreateIndex(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+
+	if err := s.CreateIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := elasticsearch.NewIndexMapping(3, "dot_product")
+	if !cmp.Equal(fake.mappings["test-index"], want) {
+		diff := cmp.Diff(fake.mappings["test-index"], want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Creating an existing index fails.
+	err := s.CreateIndex(context.Background())
+	var respErr *elasticsearch.ResponseError
+	if !errors.As(err, &respErr) {
+		t.Fatalf("Got error (%v), want *ResponseError", err)
+	}
+	if respErr.StatusCode != http.StatusBadRequest || respErr.Type != "resource_already_exists_exception" {
+		t.Errorf("Got unexpected error: %v", respErr)
+	}
+}
+
+func TestStore_UpsertAndSearch(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+	ctx := context.Background()
+
+	docs := []elasticsearch.Document{
+		{ID: "1", Title: "t1", Paragraph: "p1", Vector: []float32{1, 0, 0}},
+		{ID: "2", Title: "t2", Paragraph: "p2", Vector: []float32{0, 1, 0}},
+		{ID: "3", Title: "t3", Paragraph: "p3", Vector: []float32{0.6, 0.8, 0}},
+	}
+	if err := s.Upsert(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upserting the same ID replaces the document.
+	docs[1].Paragraph = "p2-new"
+	if err := s.Upsert(ctx, docs[1:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	gotHits, err := s.Search(ctx, []float32{0, 1, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHits := []elasticsearch.Hit{
+		{ID: "2", Score: 1, Title: "t2", Paragraph: "p2-new"},
+		{ID: "3", Score: 0.8, Title: "t3", Paragraph: "p3"},
+	}
+	if !cmp.Equal(gotHits, wantHits) {
+		diff := cmp.Diff(gotHits, wantHits)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	if err := s.Upsert(ctx, []elasticsearch.Document{{ID: "4", Vector: []float32{1}}}); err == nil {
+		t.Errorf("Want error for mismatched dimension")
+	}
+}
+
+func TestStore_Search_NumCandidates(t *testing.T) {
+	tests := []struct {
+		name              string
+		inNumCandidates   int
+		inK               int
+		wantNumCandidates int
+	}{
+		{
+			name:              "default",
+			inK:               5,
+			wantNumCandidates: 50,
+		},
+		{
+			name:              "explicit",
+			inNumCandidates:   200,
+			inK:               5,
+			wantNumCandidates: 200,
+		},
+		{
+			name:              "fewer than k",
+			inNumCandidates:   50,
+			inK:               100,
+			wantNumCandidates: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeES()
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			s, err := elasticsearch.New(&elasticsearch.Config{
+				Client:        es.Config{Addresses: []string{server.URL}},
+				Index:         "test-index",
+				Dims:          3,
+				NumCandidates: tt.inNumCandidates,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := s.Search(context.Background(), []float32{1, 0, 0}, tt.inK); err != nil {
+				t.Fatal(err)
+			}
+			if fake.numCandidates != tt.wantNumCandidates {
+				t.Errorf("Got num_candidates %d, want %d", fake.numCandidates, tt.wantNumCandidates)
+			}
+		})
+	}
+}
+
+func TestStore_Upsert_BulkError(t *testing.T) {
+	fake := newFakeES()
+	fake.rejectID = "bad"
+	s := newStore(t, fake, "test-index")
+
+	err := s.Upsert(context.Background(), []elasticsearch.Document{
+		{ID: "good", Vector: []float32{1, 0, 0}},
+		{ID: "bad", Vector: []float32{0, 1, 0}},
+	})
+
+	var bulkErr *elasticsearch.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("Got error (%v), want *BulkError", err)
+	}
+	wantFailures := []elasticsearch.BulkFailure{
+		{Index: 1, ID: "bad", Status: 400, Type: "mapper_parsing_exception", Reason: "rejected"},
+	}
+	if !cmp.Equal(bulkErr.Failures, wantFailures) {
+		diff := cmp.Diff(bulkErr.Failures, wantFailures)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestStore_EncodeAndSearch(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+	ctx := context.Background()
+	enc := retrievaltest.Encoder{
+		"p1":    {1, 0, 0},
+		"p2":    {0, 1, 0},
+		"query": {0.8, 0.6, 0},
+	}
+
+	err := s.EncodeAndUpsert(ctx, enc, []elasticsearch.Document{
+		{ID: "1", Title: "t1", Paragraph: "p1"},
+		{ID: "2", Title: "t2", Paragraph: "p2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotHits, err := s.EncodeAndSearch(ctx, enc, "query", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHits := []elasticsearch.Hit{
+		{ID: "1", Score: 0.8, Title: "t1", Paragraph: "p1"},
+	}
+	if !cmp.Equal(gotHits, wantHits) {
+		diff := cmp.Diff(gotHits, wantHits)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestStore_EncodeAndUpsert_LengthMismatch(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+	docs := []elasticsearch.Document{{ID: "1", Paragraph: "p1"}, {ID: "2", Paragraph: "p2"}}
+
+	err := s.EncodeAndUpsert(context.Background(), shortEncoder{}, docs)
+	if err == nil {
+		t.Fatal("Want error for missing vectors")
+	}
+	if len(fake.docs) != 0 {
+		t.Errorf("Want no documents upserted, got %d", len(fake.docs))
+	}
+}
+
+func TestStore_EncodeContext(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+	ctx := withRequestID(context.Background(), "req-1")
+	enc := &contextEncoder{Encoder: retrievaltest.Encoder{"p1": {1, 0, 0}}}
+
+	err := s.EncodeAndUpsert(ctx, enc, []elasticsearch.Document{{ID: "1", Paragraph: "p1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EncodeAndSearch(ctx, enc, "p1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The context of the store methods is passed to the encoder.
+	want := []string{"req-1", "req-1"}
+	if !cmp.Equal(enc.requestIDs, want) {
+		diff := cmp.Diff(want, enc.requestIDs)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func TestStore_Fingerprint(t *testing.T) {
+	fake := newFakeES()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	ctx := context.Background()
+
+	newStore := func(fingerprint string) *elasticsearch.Store {
+		s, err := elasticsearch.New(&elasticsearch.Config{
+			Client:      es.Config{Addresses: []string{server.URL}},
+			Index:       "test-index",
+			Dims:        3,
+			Fingerprint: fingerprint,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := newStore("fp1")
+	if err := s.CreateIndex(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckFingerprint(ctx); err != nil {
+		t.Errorf("Want no error, got %v", err)
+	}
+	if err := newStore("fp2").CheckFingerprint(ctx); !errors.Is(err, fingerprint.ErrMismatch) {
+		t.Errorf("Want ErrFingerprintMismatch, got %v", err)
+	}
+
+	// Upsert and Search check the index automatically.
+	other := newStore("fp2")
+	vectorDocs := []elasticsearch.Document{{ID: "1", Vector: []float32{1, 0, 0}}}
+	if err := other.Upsert(ctx, vectorDocs); !errors.Is(err, fingerprint.ErrMismatch) {
+		t.Errorf("Upsert: Want ErrFingerprintMismatch, got %v", err)
+	}
+	if _, err := other.Search(ctx, []float32{1, 0, 0}, 1); !errors.Is(err, fingerprint.ErrMismatch) {
+		t.Errorf("Search: Want ErrFingerprintMismatch, got %v", err)
+	}
+	if err := newStore("fp1").Upsert(ctx, vectorDocs); err != nil {
+		t.Errorf("Upsert: Want no error, got %v", err)
+	}
+
+	docs := []elasticsearch.Document{{ID: "1", Paragraph: "p1"}}
+	enc := retrievaltest.Encoder{"p1": {1, 0, 0}}
+	if err := s.EncodeAndUpsert(ctx, fingerprintedEncoder{enc, "fp1"}, docs); err != nil {
+		t.Errorf("Want no error, got %v", err)
+	}
+	if err := s.EncodeAndUpsert(ctx, fingerprintedEncoder{enc, "fp2"}, docs); !errors.Is(err, fingerprint.ErrMismatch) {
+		t.Errorf("Want ErrFingerprintMismatch, got %v", err)
+	}
+	// Encoders without fingerprints are not checked.
+	if err := s.EncodeAndUpsert(ctx, enc, docs); err != nil {
+		t.Errorf("Want no error, got %v", err)
+	}
+}
+
+func TestStore_Retrievers(t *testing.T) {
+	fake := newFakeES()
+	s := newStore(t, fake, "test-index")
+	ctx := context.Background()
+
+	err := s.Upsert(ctx, []elasticsearch.Document{
+		{ID: "1", Title: "Go", Paragraph: "go is a programming language", Vector: []float32{1, 0, 0}},
+		{ID: "2", Title: "Noodles", Paragraph: "a recipe for noodles", Vector: []float32{0, 1, 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		retriever retrieval.Retriever
+		inQuery   string
+		wantHits  []retrieval.Hit
+	}{
+		{
+			name:      "lexical",
+			retriever: s.LexicalRetriever(),
+			inQuery:   "programming language",
+			wantHits: []retrieval.Hit{
+				{Document: retrieval.Document{ID: "1", Title: "Go", Para: "go is a programming language"}, Score: 2},
+			},
+		},
+		{
+			name:      "dense",
+			retriever: s.DenseRetriever(retrievaltest.Encoder{"noodles": {0, 1, 0}}),
+			inQuery:   "noodles",
+			wantHits: []retrieval.Hit{
+				{Document: retrieval.Document{ID: "2", Title: "Noodles", Para: "a recipe for noodles"}, Score: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotHits, err := tt.retriever.Retrieve(ctx, tt.inQuery, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cmp.Equal(gotHits, tt.wantHits) {
+				diff := cmp.Diff(gotHits, tt.wantHits)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func newStore(t *testing.T, fake *fakeES, index string) *elasticsearch.Store {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	s, err := elasticsearch.New(&elasticsearch.Config{
+		Client: es.Config{Addresses: []string{server.URL}},
+		Index:  index,
+		Dims:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// fingerprintedEncoder is a retrievaltest.Encoder with a fingerprint.
+type fingerprintedEncoder struct {
+	retrievaltest.Encoder
+	fingerprint string
+}
+
+func (e fingerprintedEncoder) Fingerprint() string {
+	return e.fingerprint
+}
+
+// contextEncoder is a retrievaltest.Encoder accepting contexts, which
+// records their request IDs.
+type contextEncoder struct {
+	retrievaltest.Encoder
+	requestIDs []string
+}
+
+func (e *contextEncoder) EncodeQueries(ctx context.Context, queries []string) ([]vecmath.Vector, error) {
+	e.requestIDs = append(e.requestIDs, requestID(ctx))
+	return e.Encoder.EncodeQueries(ctx, queries)
+}
+
+func (e *contextEncoder) EncodeParaContext(ctx context.Context, paras, titles []string) ([]vecmath.Vector, error) {
+	e.requestIDs = append(e.requestIDs, requestID(ctx))
+	return e.EncodePara(paras, titles)
+}
+
+// shortEncoder is a misbehaving encoder, which returns one vector whatever
+// the number of paragraphs.
+type shortEncoder struct{}
+
+func (shortEncoder) EncodePara(paras, titles []string) ([]vecmath.Vector, error) {
+	return []vecmath.Vector{{1, 0, 0}}, nil
+}
+
+// requestIDKey is the context key of the request IDs recorded by
+// contextEncoder.
+type requestIDKey struct{}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// fakeES is an in-memory fake of the Elasticsearch APIs used by Store.
+type fakeES struct {
+	mu       sync.Mutex
+	mappings map[string]elasticsearch.IndexMapping
+	docs     map[string]elasticsearch.Document
+	// rejectID is the ID of the document to reject in bulk requests.
+	rejectID string
+	// numCandidates is the num_candidates of the last kNN search.
+	numCandidates int
+}
+
+func newFakeES() *fakeES {
+	return &fakeES{
+		mappings: make(map[string]elasticsearch.IndexMapping),
+		docs:     make(map[string]elasticsearch.Document),
+	}
+}
+
+func (f *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPut && len(parts) == 1:
+		f.createIndex(w, r, parts[0])
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "_mapping":
+		f.getMapping(w, parts[0])
+	case len(parts) == 2 && parts[1] == "_bulk":
+		f.bulk(w, r)
+	case len(parts) == 2 && parts[1] == "_search":
+		f.search(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeES) createIndex(w http.ResponseWriter, r *http.Request, index string) {
+	if _, ok := f.mappings[index]; ok {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]interface{}{
+			"error":  map[string]string{"type": "resource_already_exists_exception", "reason": "index already exists"},
+			"status": 400,
+		})
+		return
+	}
+
+	var m elasticsearch.IndexMapping
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mappings[index] = m
+	writeJSON(w, map[string]interface{}{"acknowledged": true})
+}
+
+func (f *fakeES) getMapping(w http.ResponseWriter, index string) {
+	m, ok := f.mappings[index]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]interface{}{
+			"error":  map[string]string{"type": "index_not_found_exception", "reason": "no such index"},
+			"status": 404,
+		})
+		return
+	}
+	writeJSON(w, map[string]interface{}{index: m})
+}
+
+func (f *fakeES) bulk(w http.ResponseWriter, r *http.Request) {
+	type doc struct {
+		Title     string    `json:"title"`
+		Paragraph string    `json:"paragraph"`
+		Vector    []float32 `json:"vector"`
+	}
+
+	var items []interface{}
+	hasErrors := false
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var action struct {
+			Index struct {
+				ID string `json:"_id"`
+			} `json:"index"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		scanner.Scan()
+		var d doc
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id := action.Index.ID
+		if id == f.rejectID {
+			hasErrors = true
+			items = append(items, map[string]interface{}{"index": map[string]interface{}{
+				"_id":    id,
+				"status": 400,
+				"error":  map[string]string{"type": "mapper_parsing_exception", "reason": "rejected"},
+			}})
+			continue
+		}
+
+		f.docs[id] = elasticsearch.Document{ID: id, Title: d.Title, Paragraph: d.Paragraph, Vector: d.Vector}
+		items = append(items, map[string]interface{}{"index": map[string]interface{}{"_id": id, "status": 201}})
+	}
+
+	writeJSON(w, map[string]interface{}{"errors": hasErrors, "items": items})
+}
+
+func (f *fakeES) search(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Knn *struct {
+			QueryVector   []float32 `json:"query_vector"`
+			K             int       `json:"k"`
+			NumCandidates int       `json:"num_candidates"`
+		} `json:"knn"`
+		Query *struct {
+			MultiMatch struct {
+				Query string `json:"query"`
+			} `json:"multi_match"`
+		} `json:"query"`
+		Size int `json:"size"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Knn != nil {
+		f.numCandidates = req.Knn.NumCandidates
+	}
+
+	var docs []elasticsearch.Document
+	for _, d := range f.docs {
+		docs = append(docs, d)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+
+	// Score documents by dot product for kNN search, or by the number of
+	// matched words for full-text search.
+	var scores []float32
+	for _, d := range docs {
+		if req.Knn != nil {
+			scores = append(scores, vecmath.Dot(req.Knn.QueryVector, d.Vector))
+			continue
+		}
+		var n float32
+		for _, word := range strings.Fields(req.Query.MultiMatch.Query) {
+			if strings.Contains(d.Title+" "+d.Paragraph, word) {
+				n++
+			}
+		}
+		scores = append(scores, n)
+	}
+
+	var hits []interface{}
+	for _, m := range vecmath.TopK(scores, req.Size) {
+		if m.Score <= 0 {
+			break
+		}
+		d := docs[m.Index]
+		hits = append(hits, map[string]interface{}{
+			"_id":     d.ID,
+			"_score":  m.Score,
+			"_source": map[string]string{"title": d.Title, "paragraph": d.Paragraph},
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	_ = json.NewEncoder(w).Encode(v)
+}
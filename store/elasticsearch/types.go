@@ -0,0 +1,178 @@
+package elasticsearch
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	titleField     = "title"
+	paragraphField = "paragraph"
+	vectorField    = "vector"
+
+	fingerprintMetaKey = "rocketqa_fingerprint"
+)
+
+// Document is a paragraph stored in the index.
+type Document struct {
+	ID        string    `json:"-"`
+	Title     string    `json:"title"`
+	Paragraph string    `json:"paragraph"`
+	Vector    []float32 `json:"vector,omitempty"`
+}
+
+// Hit is a document returned by a search.
+type Hit struct {
+	ID        string
+	Score     float64
+	Title     string
+	Paragraph string
+}
+
+// IndexMapping is the mapping of the index.
+type IndexMapping struct {
+	Mappings Mappings `json:"mappings"`
+}
+
+type Mappings struct {
+	// Custom metadata, which records the encoder fingerprint.
+	Meta       map[string]any      `json:"_meta,omitempty"`
+	Source     SourceFilter        `json:"_source"`
+	Properties map[string]Property `json:"properties"`
+}
+
+type SourceFilter struct {
+	Excludes []string `json:"excludes,omitempty"`
+}
+
+type Property struct {
+	Type       string `json:"type"`
+	Dims       int    `json:"dims,omitempty"`
+	Index      bool   `json:"index,omitempty"`
+	Similarity string `json:"similarity,omitempty"`
+}
+
+// NewIndexMapping creates a mapping that indexes the title and the paragraph
+// as text, and the vector as a dense vector for kNN search. The vector is
+// excluded from the stored source to save space.
+func NewIndexMapping(dims int, similarity string) IndexMapping {
+	return IndexMapping{
+		Mappings: Mappings{
+			Source: SourceFilter{Excludes: []string{vectorField}},
+			Properties: map[string]Property{
+				vectorField: {
+					Type:       "dense_vector",
+					Dims:       dims,
+					Index:      true,
+					Similarity: similarity,
+				},
+				titleField:     {Type: "text"},
+				paragraphField: {Type: "text"},
+			},
+		},
+	}
+}
+
+// Dims returns the dimension of the vectors in the mapping.
+func (m IndexMapping) Dims() int {
+	return m.Mappings.Properties[vectorField].Dims
+}
+
+// ResponseError is an error returned by Elasticsearch.
+type ResponseError struct {
+	StatusCode int
+	Type       string
+	Reason     string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("elasticsearch: [%d] %s", e.StatusCode, e.Reason)
+	}
+	return fmt.Sprintf("elasticsearch: [%d] %s: %s", e.StatusCode, e.Type, e.Reason)
+}
+
+// BulkFailure describes a document that failed in a bulk request.
+type BulkFailure struct {
+	// The position of the document in the request.
+	Index  int
+	ID     string
+	Status int
+	Type   string
+	Reason string
+}
+
+// BulkError is returned when some of the documents in a bulk request fail.
+type BulkError struct {
+	Failures []BulkFailure
+}
+
+func (e *BulkError) Error() string {
+	var reasons []string
+	for _, f := range e.Failures {
+		reasons = append(reasons, fmt.Sprintf("docs[%d] (id=%q): %s: %s", f.Index, f.ID, f.Type, f.Reason))
+	}
+	return fmt.Sprintf("elasticsearch: %d documents failed: %s", len(e.Failures), strings.Join(reasons, "; "))
+}
+
+type bulkAction struct {
+	Index bulkMeta `json:"index"`
+}
+
+type bulkMeta struct {
+	ID string `json:"_id,omitempty"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []struct {
+		Index struct {
+			ID     string `json:"_id"`
+			Status int    `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"index"`
+	} `json:"items"`
+}
+
+type knnQuery struct {
+	Field         string    `json:"field"`
+	QueryVector   []float32 `json:"query_vector"`
+	K             int       `json:"k"`
+	NumCandidates int       `json:"num_candidates"`
+}
+
+type textQuery struct {
+	MultiMatch multiMatch `json:"multi_match"`
+}
+
+type multiMatch struct {
+	Query  string   `json:"query"`
+	Fields []string `json:"fields"`
+}
+
+type searchRequest struct {
+	Knn    *knnQuery  `json:"knn,omitempty"`
+	Query  *textQuery `json:"query,omitempty"`
+	Source []string   `json:"_source,omitempty"`
+	Size   int        `json:"size"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID     string   `json:"_id"`
+			Score  float64  `json:"_score"`
+			Source Document `json:"_source"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
@@ -0,0 +1,58 @@
+package rocketqa
+
+import (
+	"fmt"
+)
+
+// LengthError reports that an argument of an encoder method does not have the
+// same length as the argument it pairs with. It matches ErrLengthMismatch.
+type LengthError struct {
+	// The name and the length of the argument, such as "titles".
+	Arg string
+	Len int
+	// The name and the length of the argument it pairs with, such as
+	// "paras".
+	WantArg string
+	WantLen int
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("len(%s) = %d does not equal len(%s) = %d", e.Arg, e.Len, e.WantArg, e.WantLen)
+}
+
+func (e *LengthError) Is(target error) bool {
+	return target == ErrLengthMismatch
+}
+
+// The titles are optional in all the encoder methods: they are either empty,
+// meaning that no paragraph has a title, or of the same length as the
+// paragraphs, in which case any of them can be empty. An empty title is
+// replaced by the configured placeholder, if any.
+
+// checkTitles checks that titles is empty or pairs with paras.
+func checkTitles(paras, titles []string) error {
+	if len(titles) > 0 && len(titles) != len(paras) {
+		return &LengthError{Arg: "titles", Len: len(titles), WantArg: "paras", WantLen: len(paras)}
+	}
+	return nil
+}
+
+// checkTriples checks that paras pairs with queries, and that titles is empty
+// or pairs with queries.
+func checkTriples(queries, paras, titles []string) error {
+	if len(paras) != len(queries) {
+		return &LengthError{Arg: "paras", Len: len(paras), WantArg: "queries", WantLen: len(queries)}
+	}
+	if len(titles) > 0 && len(titles) != len(queries) {
+		return &LengthError{Arg: "titles", Len: len(titles), WantArg: "queries", WantLen: len(queries)}
+	}
+	return nil
+}
+
+// titleAt returns the i-th title, or the empty string if there are no titles.
+func titleAt(titles []string, i int) string {
+	if len(titles) == 0 {
+		return ""
+	}
+	return titles[i]
+}
@@ -0,0 +1,110 @@
+package rocketqa_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-aie/rocketqa"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDualEncoder_EncodePara_Titles(t *testing.T) {
+	de, err := newDualEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := newDualEncoderConfig(1)
+	cfg.TitlePlaceholder = "-"
+	dePlaceholder, err := rocketqa.NewDualEncoder(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paras := []string{"这是一段较长的文本。", "This is a long paragraph."}
+
+	// No titles mean empty titles.
+	want, err := de.EncodePara(paras, []string{"", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := de.EncodePara(paras, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	// Empty titles are replaced by the placeholder.
+	want, err = de.EncodePara(paras, []string{"-", "-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = dePlaceholder.EncodePara(paras, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(want, got)
+		t.Errorf("Want - Got: %s", diff)
+	}
+
+	_, err = de.EncodePara(paras, []string{"标题"})
+	wantErr := &rocketqa.LengthError{Arg: "titles", Len: 1, WantArg: "paras", WantLen: 2}
+	var gotErr *rocketqa.LengthError
+	if !errors.As(err, &gotErr) || !cmp.Equal(gotErr, wantErr) {
+		t.Errorf("got error %v, want %v", err, wantErr)
+	}
+}
+
+func TestCrossEncoder_Rank_Titles(t *testing.T) {
+	ce, err := newCrossEncoder(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		inParas  []string
+		inTitles []string
+		wantErr  *rocketqa.LengthError
+	}{
+		{
+			name:    "no titles",
+			inParas: []string{"p1", "p2"},
+		},
+		{
+			name:     "empty titles",
+			inParas:  []string{"p1", "p2"},
+			inTitles: []string{"", "标题"},
+		},
+		{
+			name:    "too few paras",
+			inParas: []string{"p1"},
+			wantErr: &rocketqa.LengthError{Arg: "paras", Len: 1, WantArg: "queries", WantLen: 2},
+		},
+		{
+			name:     "too many titles",
+			inParas:  []string{"p1", "p2"},
+			inTitles: []string{"t1", "t2", "t3"},
+			wantErr:  &rocketqa.LengthError{Arg: "titles", Len: 3, WantArg: "queries", WantLen: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ce.Rank([]string{"q1", "q2"}, tt.inParas, tt.inTitles)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			var gotErr *rocketqa.LengthError
+			if !errors.As(err, &gotErr) || !cmp.Equal(gotErr, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
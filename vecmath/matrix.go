@@ -0,0 +1,59 @@
+package vecmath
+
+// DotMatrix returns the dot products between every query and every para,
+// where result[i][j] is the dot product of queries[i] and paras[j].
+func DotMatrix[V ~[]float32](queries, paras []V) [][]float32 {
+	result := make([][]float32, len(queries))
+	// Allocate all the rows at once to keep them contiguous in memory.
+	data := make([]float32, len(queries)*len(paras))
+	for i, q := range queries {
+		row := data[i*len(paras) : (i+1)*len(paras) : (i+1)*len(paras)]
+		for j, p := range paras {
+			row[j] = Dot(q, p)
+		}
+		result[i] = row
+	}
+	return result
+}
+
+// CosineMatrix returns the cosine similarities between every query and every
+// para, where result[i][j] is the cosine similarity of queries[i] and paras[j].
+func CosineMatrix[V ~[]float32](queries, paras []V) [][]float32 {
+	qNorms := norms(queries)
+	pNorms := norms(paras)
+
+	result := DotMatrix(queries, paras)
+	for i, row := range result {
+		for j := range row {
+			if d := qNorms[i] * pNorms[j]; d != 0 {
+				row[j] /= d
+			} else {
+				row[j] = 0
+			}
+		}
+	}
+	return result
+}
+
+// L2Matrix returns the Euclidean distances between every query and every
+// para, where result[i][j] is the distance between queries[i] and paras[j].
+func L2Matrix[V ~[]float32](queries, paras []V) [][]float32 {
+	result := make([][]float32, len(queries))
+	data := make([]float32, len(queries)*len(paras))
+	for i, q := range queries {
+		row := data[i*len(paras) : (i+1)*len(paras) : (i+1)*len(paras)]
+		for j, p := range paras {
+			row[j] = L2(q, p)
+		}
+		result[i] = row
+	}
+	return result
+}
+
+func norms[V ~[]float32](vs []V) []float32 {
+	result := make([]float32, len(vs))
+	for i, v := range vs {
+		result[i] = Norm(v)
+	}
+	return result
+}
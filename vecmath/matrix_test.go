@@ -0,0 +1,69 @@
+package vecmath_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMatrix(t *testing.T) {
+	queries := []Vector{{1, 0}, {3, 4}}
+	paras := []Vector{{1, 0}, {0, 2}, {0, 0}}
+
+	tests := []struct {
+		name string
+		fn   func(q, p []Vector) [][]float32
+		want [][]float32
+	}{
+		{
+			name: "dot",
+			fn:   vecmath.DotMatrix[Vector],
+			want: [][]float32{{1, 0, 0}, {3, 8, 0}},
+		},
+		{
+			name: "cosine",
+			fn:   vecmath.CosineMatrix[Vector],
+			want: [][]float32{{1, 0, 0}, {0.6, 0.8, 0}},
+		},
+		{
+			name: "l2",
+			fn:   vecmath.L2Matrix[Vector],
+			want: [][]float32{{0, 2.236068, 1}, {4.472136, 3.6055512, 5}},
+		},
+	}
+	opt := cmpopts.EquateApprox(0, 1e-6)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.fn(queries, paras)
+			if !cmp.Equal(got, tt.want, opt) {
+				diff := cmp.Diff(got, tt.want)
+				t.Errorf("Want - Got: %s", diff)
+			}
+		})
+	}
+}
+
+func BenchmarkDotMatrix(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	queries := randomVectors(r, 8, 768)
+	paras := randomVectors(r, 1000, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.DotMatrix(queries, paras)
+	}
+}
+
+func BenchmarkCosineMatrix(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	queries := randomVectors(r, 8, 768)
+	paras := randomVectors(r, 1000, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.CosineMatrix(queries, paras)
+	}
+}
@@ -0,0 +1,93 @@
+package vecmath
+
+import (
+	"sort"
+)
+
+// Match is a scored position returned by TopK.
+type Match struct {
+	Index int
+	Score float32
+}
+
+// TopK returns the k highest scores in descending order, along with their
+// indices in scores. Ties are broken by the lower index. If k is greater
+// than len(scores), all the scores are returned.
+func TopK(scores []float32, k int) []Match {
+	if k > len(scores) {
+		k = len(scores)
+	}
+	if k <= 0 {
+		return nil
+	}
+
+	// h is a min-heap holding the best k matches seen so far, whose root
+	// is the worst one among them.
+	h := make(minHeap, 0, k)
+	for i, s := range scores {
+		m := Match{Index: i, Score: s}
+		if len(h) < k {
+			h.push(m)
+		} else if worse(h[0], m) {
+			h[0] = m
+			h.down(0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool { return worse(h[j], h[i]) })
+	return h
+}
+
+// TopKVectors returns the k vectors in candidates that are most similar to
+// query by dot product, in descending order of similarity.
+func TopKVectors[V ~[]float32](query V, candidates []V, k int) []Match {
+	scores := make([]float32, len(candidates))
+	for i, c := range candidates {
+		scores[i] = Dot(query, c)
+	}
+	return TopK(scores, k)
+}
+
+// worse reports whether a ranks below b.
+func worse(a, b Match) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Index > b.Index
+}
+
+type minHeap []Match
+
+func (h *minHeap) push(m Match) {
+	*h = append(*h, m)
+	h.up(len(*h) - 1)
+}
+
+func (h minHeap) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !worse(h[i], h[parent]) {
+			break
+		}
+		h[i], h[parent] = h[parent], h[i]
+		i = parent
+	}
+}
+
+func (h minHeap) down(i int) {
+	n := len(h)
+	for {
+		smallest := i
+		if l := 2*i + 1; l < n && worse(h[l], h[smallest]) {
+			smallest = l
+		}
+		if r := 2*i + 2; r < n && worse(h[r], h[smallest]) {
+			smallest = r
+		}
+		if smallest == i {
+			return
+		}
+		h[i], h[smallest] = h[smallest], h[i]
+		i = smallest
+	}
+}
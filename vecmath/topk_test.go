@@ -0,0 +1,70 @@
+package vecmath_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTopK(t *testing.T) {
+	tests := []struct {
+		inScores    []float32
+		inK         int
+		wantMatches []vecmath.Match
+	}{
+		{
+			inScores:    []float32{0.1, 0.9, 0.5, 0.7},
+			inK:         2,
+			wantMatches: []vecmath.Match{{Index: 1, Score: 0.9}, {Index: 3, Score: 0.7}},
+		},
+		{
+			inScores:    []float32{0.5, 0.2, 0.5, 0.5},
+			inK:         3,
+			wantMatches: []vecmath.Match{{Index: 0, Score: 0.5}, {Index: 2, Score: 0.5}, {Index: 3, Score: 0.5}},
+		},
+		{
+			inScores:    []float32{0.3, 0.4},
+			inK:         5,
+			wantMatches: []vecmath.Match{{Index: 1, Score: 0.4}, {Index: 0, Score: 0.3}},
+		},
+		{
+			inScores:    []float32{0.3, 0.4},
+			inK:         0,
+			wantMatches: nil,
+		},
+	}
+	for _, tt := range tests {
+		gotMatches := vecmath.TopK(tt.inScores, tt.inK)
+		if !cmp.Equal(gotMatches, tt.wantMatches) {
+			diff := cmp.Diff(gotMatches, tt.wantMatches)
+			t.Errorf("Want - Got: %s", diff)
+		}
+	}
+}
+
+func TestTopKVectors(t *testing.T) {
+	query := Vector{1, 0}
+	candidates := []Vector{{0, 1}, {2, 0}, {1, 1}}
+
+	got := vecmath.TopKVectors(query, candidates, 2)
+	want := []vecmath.Match{{Index: 1, Score: 2}, {Index: 2, Score: 1}}
+	if !cmp.Equal(got, want) {
+		diff := cmp.Diff(got, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func BenchmarkTopK(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	scores := make([]float32, 100000)
+	for i := range scores {
+		scores[i] = r.Float32()
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.TopK(scores, 10)
+	}
+}
@@ -0,0 +1,116 @@
+// Package vecmath provides pure-Go vector math for the embeddings produced
+// by the encoders.
+//
+// All functions accept any type whose underlying type is []float32, so they
+// work on rocketqa.Vector (and []rocketqa.Vector) without conversions.
+package vecmath
+
+import (
+	"math"
+)
+
+// Dot returns the dot product of a and b.
+//
+// The loop is manually unrolled with independent accumulators, which lets
+// the compiler keep them in registers and pipeline the multiplications.
+func Dot[V ~[]float32](a, b V) float32 {
+	mustSameDim(len(a), len(b))
+
+	var s0, s1, s2, s3 float32
+	n := len(a)
+	i := 0
+	for ; i <= n-4; i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		s0 += x[0] * y[0]
+		s1 += x[1] * y[1]
+		s2 += x[2] * y[2]
+		s3 += x[3] * y[3]
+	}
+	for ; i < n; i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// Norm returns the L2 norm (the Euclidean length) of v.
+func Norm[V ~[]float32](v V) float32 {
+	return float32(math.Sqrt(float64(Dot(v, v))))
+}
+
+// Cosine returns the cosine similarity of a and b. It returns 0 if either
+// vector has zero length.
+func Cosine[V ~[]float32](a, b V) float32 {
+	na, nb := Norm(a), Norm(b)
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return Dot(a, b) / (na * nb)
+}
+
+// SquaredL2 returns the squared Euclidean distance between a and b.
+func SquaredL2[V ~[]float32](a, b V) float32 {
+	mustSameDim(len(a), len(b))
+
+	var s0, s1, s2, s3 float32
+	n := len(a)
+	i := 0
+	for ; i <= n-4; i += 4 {
+		x, y := a[i:i+4:i+4], b[i:i+4:i+4]
+		d0, d1, d2, d3 := x[0]-y[0], x[1]-y[1], x[2]-y[2], x[3]-y[3]
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < n; i++ {
+		d := a[i] - b[i]
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// L2 returns the Euclidean distance between a and b.
+func L2[V ~[]float32](a, b V) float32 {
+	return float32(math.Sqrt(float64(SquaredL2(a, b))))
+}
+
+// Normalize returns a copy of v scaled to unit length. A zero vector is
+// returned as a zero vector.
+func Normalize[V ~[]float32](v V) V {
+	out := make(V, len(v))
+	copy(out, v)
+	NormalizeInPlace(out)
+	return out
+}
+
+// NormalizeInPlace scales v to unit length in place. A zero vector is left
+// unchanged.
+func NormalizeInPlace[V ~[]float32](v V) {
+	n := Norm(v)
+	if n == 0 {
+		return
+	}
+	Scale(v, 1/n)
+}
+
+// NormalizeAll scales every vector in vs to unit length in place, and
+// returns vs for convenience.
+func NormalizeAll[V ~[]float32](vs []V) []V {
+	for _, v := range vs {
+		NormalizeInPlace(v)
+	}
+	return vs
+}
+
+// Scale multiplies every element of v by s in place.
+func Scale[V ~[]float32](v V, s float32) {
+	for i := range v {
+		v[i] *= s
+	}
+}
+
+func mustSameDim(a, b int) {
+	if a != b {
+		panic("vecmath: dimension mismatch")
+	}
+}
@@ -0,0 +1,177 @@
+package vecmath_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/go-aie/rocketqa/vecmath"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type Vector []float32
+
+func TestDot(t *testing.T) {
+	tests := []struct {
+		inA, inB Vector
+		want     float32
+	}{
+		{
+			inA:  Vector{},
+			inB:  Vector{},
+			want: 0,
+		},
+		{
+			inA:  Vector{1, 2, 3},
+			inB:  Vector{4, 5, 6},
+			want: 32,
+		},
+		{
+			inA:  Vector{1, 2, 3, 4, 5, 6, 7},
+			inB:  Vector{1, 1, 1, 1, 1, 1, -1},
+			want: 14,
+		},
+	}
+	for _, tt := range tests {
+		got := vecmath.Dot(tt.inA, tt.inB)
+		if got != tt.want {
+			t.Errorf("Got (%v) != Want (%v)", got, tt.want)
+		}
+	}
+}
+
+func TestDot_DimensionMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Want panic")
+		}
+	}()
+	vecmath.Dot(Vector{1, 2}, Vector{1})
+}
+
+func TestNormAndDistance(t *testing.T) {
+	tests := []struct {
+		inA, inB   Vector
+		wantNorm   float32
+		wantCosine float32
+		wantL2     float32
+	}{
+		{
+			inA:        Vector{3, 4},
+			inB:        Vector{4, 3},
+			wantNorm:   5,
+			wantCosine: 0.96,
+			wantL2:     1.4142135,
+		},
+		{
+			inA:        Vector{0, 0},
+			inB:        Vector{1, 0},
+			wantNorm:   0,
+			wantCosine: 0,
+			wantL2:     1,
+		},
+	}
+	opt := cmpopts.EquateApprox(0, 1e-6)
+	for _, tt := range tests {
+		if got := vecmath.Norm(tt.inA); !cmp.Equal(got, tt.wantNorm, opt) {
+			t.Errorf("Norm: Got (%v) != Want (%v)", got, tt.wantNorm)
+		}
+		if got := vecmath.Cosine(tt.inA, tt.inB); !cmp.Equal(got, tt.wantCosine, opt) {
+			t.Errorf("Cosine: Got (%v) != Want (%v)", got, tt.wantCosine)
+		}
+		if got := vecmath.L2(tt.inA, tt.inB); !cmp.Equal(got, tt.wantL2, opt) {
+			t.Errorf("L2: Got (%v) != Want (%v)", got, tt.wantL2)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   Vector
+		want Vector
+	}{
+		{
+			in:   Vector{3, 4},
+			want: Vector{0.6, 0.8},
+		},
+		{
+			in:   Vector{0, 0},
+			want: Vector{0, 0},
+		},
+	}
+	opt := cmpopts.EquateApprox(0, 1e-6)
+	for _, tt := range tests {
+		in := append(Vector(nil), tt.in...)
+		got := vecmath.Normalize(in)
+		if !cmp.Equal(got, tt.want, opt) {
+			diff := cmp.Diff(got, tt.want)
+			t.Errorf("Want - Got: %s", diff)
+		}
+		if !cmp.Equal(in, tt.in) {
+			t.Errorf("Normalize must not modify its input")
+		}
+	}
+
+	vs := vecmath.NormalizeAll([]Vector{{3, 4}, {0, 2}})
+	want := []Vector{{0.6, 0.8}, {0, 1}}
+	if !cmp.Equal(vs, want, opt) {
+		diff := cmp.Diff(vs, want)
+		t.Errorf("Want - Got: %s", diff)
+	}
+}
+
+func BenchmarkDot(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, 768), randomVector(r, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.Dot(x, y)
+	}
+}
+
+func BenchmarkCosine(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, 768), randomVector(r, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.Cosine(x, y)
+	}
+}
+
+func BenchmarkL2(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	x, y := randomVector(r, 768), randomVector(r, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.L2(x, y)
+	}
+}
+
+func BenchmarkNormalizeAll(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	vs := randomVectors(r, 100, 768)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = vecmath.NormalizeAll(vs)
+	}
+}
+
+func randomVector(r *rand.Rand, dim int) Vector {
+	v := make(Vector, dim)
+	for i := range v {
+		v[i] = float32(r.NormFloat64())
+	}
+	return v
+}
+
+func randomVectors(r *rand.Rand, n, dim int) []Vector {
+	vs := make([]Vector, n)
+	for i := range vs {
+		vs[i] = randomVector(r, dim)
+	}
+	return vs
+}
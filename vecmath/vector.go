@@ -0,0 +1,19 @@
+package vecmath
+
+// Vector is an embedding produced by the encoders. It is aliased as
+// rocketqa.Vector, and defined here so that the indexes and stores can use it
+// without depending on the inference engine.
+type Vector []float32
+
+// Norm returns a copy of v scaled to unit length.
+func (v Vector) Norm() Vector {
+	return Normalize(v)
+}
+
+func (v Vector) ToFloat64() []float64 {
+	result := make([]float64, len(v))
+	for i, x := range v {
+		result[i] = float64(x)
+	}
+	return result
+}
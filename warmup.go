@@ -0,0 +1,96 @@
+package rocketqa
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WarmUpReport describes the warm-up of an encoder.
+type WarmUpReport struct {
+	// The number of warm-up runs, one per predictor in the pool, each of
+	// which runs inputs at the maximum sequence lengths.
+	Runs int
+	// The largest number of inferences observed running at once, which is
+	// the number of predictors known to be warmed up. The pool does not
+	// tell which predictor runs an inference, so it is less than Runs if
+	// some runs finished before the others started.
+	Predictors int
+	// The wall time of the whole warm-up.
+	Duration time.Duration
+	// The latency of the slowest run.
+	MaxLatency time.Duration
+}
+
+// warmUp runs run concurrently as many times as the number of predictors in
+// the pool of e, so that every predictor runs once if the runs overlap.
+//
+// The runs are started together after a barrier, and each of them holds a
+// predictor during its inferences. Whether they overlap is observed by e,
+// and reported as WarmUpReport.Predictors.
+func warmUp(e *engine, run func() error) (WarmUpReport, error) {
+	n := cap(e.sem)
+
+	latencies := make([]time.Duration, n)
+	errs := make([]error, n)
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			begin := time.Now()
+			errs[i] = safely(run)
+			latencies[i] = time.Since(begin)
+		}(i)
+	}
+
+	e.resetPeak()
+	begin := time.Now()
+	close(start)
+	wg.Wait()
+
+	report := WarmUpReport{Runs: n, Predictors: e.peak(), Duration: time.Since(begin)}
+	for i, l := range latencies {
+		if errs[i] != nil {
+			return WarmUpReport{}, fmt.Errorf("warm-up failed: %w", errs[i])
+		}
+		if l > report.MaxLatency {
+			report.MaxLatency = l
+		}
+	}
+	return report, nil
+}
+
+// warmUpText returns a text that is tokenized into n tokens, since every
+// Chinese character is a token.
+func warmUpText(n int) string {
+	return strings.Repeat("测", max(n, 0))
+}
+
+// The warm-up inputs fill the maximum sequence lengths exactly, without
+// truncation. The titles are non-empty to keep the placeholder, if any, out
+// of the counts.
+const warmUpTitle = "测"
+
+// warmUpQuery returns a query of maxSeqLength tokens for the dual encoder,
+// including [CLS] and [SEP].
+func warmUpQuery(maxSeqLength int) string {
+	return warmUpText(maxSeqLength - 2)
+}
+
+// warmUpPara returns a para of maxSeqLength tokens for the dual encoder,
+// along with warmUpTitle, [CLS] and two [SEP]s.
+func warmUpPara(maxSeqLength int) string {
+	return warmUpText(maxSeqLength - 3 - len([]rune(warmUpTitle)))
+}
+
+// warmUpPair returns a query and a para of maxSeqLength tokens for the cross
+// encoder, along with warmUpTitle, [CLS] and two [SEP]s.
+func warmUpPair(maxSeqLength int) (query, para string) {
+	n := max(maxSeqLength-3-len([]rune(warmUpTitle)), 0)
+	return warmUpText(n / 2), warmUpText(n - n/2)
+}